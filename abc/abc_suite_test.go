@@ -0,0 +1,13 @@
+package abc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAbc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Abc Suite")
+}
@@ -0,0 +1,438 @@
+package abc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ff14wed/performgen/mml"
+)
+
+type kind int
+
+const (
+	kNote kind = iota
+	kRest
+	kBar
+	kEnding
+	kField
+	kTuplet
+	kBroken
+)
+
+// pitch is a single note as written, before the key signature is applied
+type pitch struct {
+	letter     byte
+	octave     int
+	accidental int
+	explicit   bool
+}
+
+// element is a single symbol of the tune body
+type element struct {
+	kind kind
+	pos  mml.Position
+
+	// Notes and chords (kNote) and rests (kRest)
+	pitches []pitch
+	length  float64
+	tie     bool
+	bars    int
+
+	// Bar lines (kBar)
+	startRepeat bool
+	endRepeat   bool
+	thick       bool
+
+	// Alternate endings (kEnding)
+	endings []int
+
+	// Inline fields (kField)
+	name  byte
+	value string
+
+	// Tuplets (kTuplet) and broken rhythms (kBroken, where p is the number of
+	// > or < symbols and q is -1 for <)
+	p, q, r int
+}
+
+// tokenize splits the lines of a tune body into elements
+func tokenize(lines []Line) ([]element, error) {
+	var els []element
+	for _, line := range lines {
+		if name, value, ok := field(strings.TrimSpace(line.Text)); ok {
+			els = append(els, element{
+				kind:  kField,
+				pos:   mml.Position{Line: line.Number, Column: 1},
+				name:  name,
+				value: value,
+			})
+			continue
+		}
+		l := &lexer{text: line.Text, line: line.Number}
+		lineEls, err := l.run()
+		if err != nil {
+			return nil, err
+		}
+		els = append(els, lineEls...)
+	}
+	applyBrokenRhythms(els)
+	return els, nil
+}
+
+type lexer struct {
+	text string
+	line int
+	i    int
+	els  []element
+}
+
+func (l *lexer) pos() mml.Position {
+	return mml.Position{Line: l.line, Column: l.i + 1}
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.i+offset < len(l.text) {
+		return l.text[l.i+offset]
+	}
+	return 0
+}
+
+// skipPast skips to the character after the next occurrence of c
+func (l *lexer) skipPast(c byte) {
+	idx := strings.IndexByte(l.text[l.i+1:], c)
+	if idx == -1 {
+		l.i = len(l.text)
+		return
+	}
+	l.i += idx + 2
+}
+
+func (l *lexer) run() ([]element, error) {
+	for l.i < len(l.text) {
+		c := l.text[l.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '`' || c == '\\' || c == 'y' || c == ')':
+			l.i++
+		case c == '"' || c == '!' || c == '+' || c == '{':
+			end := c
+			if c == '{' {
+				end = '}'
+			}
+			l.skipPast(end)
+		case strings.IndexByte(".~HLMOPSTuv", c) != -1:
+			l.i++
+		case c == '(':
+			if isDigit(l.peek(1)) {
+				l.tuplet()
+			} else {
+				l.i++
+			}
+		case c == '>' || c == '<':
+			l.broken()
+		case c == '-':
+			if n := len(l.els); n > 0 && l.els[n-1].kind == kNote {
+				l.els[n-1].tie = true
+			}
+			l.i++
+		case c == '|' || c == ':' || (c == '[' && l.peek(1) == '|'):
+			l.bar()
+		case c == '[' && isDigit(l.peek(1)):
+			l.i++
+			l.ending(l.pos())
+		case c == '[' && l.peek(2) == ':':
+			if err := l.inlineField(); err != nil {
+				return nil, err
+			}
+		case c == '[':
+			if err := l.chord(); err != nil {
+				return nil, err
+			}
+		case c == '^' || c == '_' || c == '=' || isNoteLetter(c):
+			pos := l.pos()
+			p, err := l.pitch()
+			if err != nil {
+				return nil, err
+			}
+			length, err := l.length()
+			if err != nil {
+				return nil, err
+			}
+			l.els = append(l.els, element{kind: kNote, pos: pos, pitches: []pitch{p}, length: length})
+		case c == 'z' || c == 'x':
+			pos := l.pos()
+			l.i++
+			length, err := l.length()
+			if err != nil {
+				return nil, err
+			}
+			l.els = append(l.els, element{kind: kRest, pos: pos, length: length})
+		case c == 'Z' || c == 'X':
+			pos := l.pos()
+			l.i++
+			bars := l.number(1)
+			l.els = append(l.els, element{kind: kRest, pos: pos, bars: bars})
+		default:
+			return nil, fmt.Errorf("invalid symbol '%c' at %s", c, l.pos())
+		}
+	}
+	return l.els, nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isNoteLetter(c byte) bool { return (c >= 'A' && c <= 'G') || (c >= 'a' && c <= 'g') }
+
+// number reads a whole number, returning def if there isn't one
+func (l *lexer) number(def int) int {
+	start := l.i
+	for l.i < len(l.text) && isDigit(l.text[l.i]) {
+		l.i++
+	}
+	if start == l.i {
+		return def
+	}
+	n, err := strconv.Atoi(l.text[start:l.i])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// length reads a note length multiplier like 2, 3/2, /, or //
+func (l *lexer) length() (float64, error) {
+	length := float64(l.number(1))
+	for l.peek(0) == '/' {
+		pos := l.pos()
+		l.i++
+		divisor := l.number(2)
+		if divisor == 0 {
+			return 0, fmt.Errorf("invalid note length: division by 0 at %s", pos)
+		}
+		length /= float64(divisor)
+	}
+	return length, nil
+}
+
+// pitch reads the accidentals, letter, and octave marks of a note
+func (l *lexer) pitch() (pitch, error) {
+	var p pitch
+	for {
+		switch l.peek(0) {
+		case '^':
+			p.accidental++
+			p.explicit = true
+			l.i++
+			continue
+		case '_':
+			p.accidental--
+			p.explicit = true
+			l.i++
+			continue
+		case '=':
+			p.explicit = true
+			l.i++
+			continue
+		}
+		break
+	}
+	c := l.peek(0)
+	if !isNoteLetter(c) {
+		return p, fmt.Errorf("expected note after accidental at %s", l.pos())
+	}
+	l.i++
+	p.octave = 4
+	if c >= 'a' {
+		p.octave = 5
+		c -= 'a' - 'A'
+	}
+	p.letter = c
+	for {
+		switch l.peek(0) {
+		case '\'':
+			p.octave++
+			l.i++
+			continue
+		case ',':
+			p.octave--
+			l.i++
+			continue
+		}
+		break
+	}
+	return p, nil
+}
+
+func (l *lexer) chord() error {
+	pos := l.pos()
+	l.i++
+	el := element{kind: kNote, pos: pos}
+	first := true
+	for l.i < len(l.text) && l.text[l.i] != ']' {
+		c := l.text[l.i]
+		switch {
+		case c == '^' || c == '_' || c == '=' || isNoteLetter(c):
+			p, err := l.pitch()
+			if err != nil {
+				return err
+			}
+			length, err := l.length()
+			if err != nil {
+				return err
+			}
+			if first {
+				el.length = length
+				first = false
+			}
+			el.pitches = append(el.pitches, p)
+		case c == '-':
+			el.tie = true
+			l.i++
+		case c == '"' || c == '!' || c == '+':
+			l.skipPast(c)
+		default:
+			l.i++
+		}
+	}
+	if l.i >= len(l.text) {
+		return fmt.Errorf("unterminated chord at %s", pos)
+	}
+	l.i++
+	if len(el.pitches) == 0 {
+		return fmt.Errorf("empty chord at %s", pos)
+	}
+	length, err := l.length()
+	if err != nil {
+		return err
+	}
+	el.length *= length
+	l.els = append(l.els, el)
+	return nil
+}
+
+func (l *lexer) inlineField() error {
+	pos := l.pos()
+	end := strings.IndexByte(l.text[l.i:], ']')
+	if end == -1 {
+		return fmt.Errorf("unterminated inline field at %s", pos)
+	}
+	name, value, _ := field(l.text[l.i+1 : l.i+end])
+	l.els = append(l.els, element{kind: kField, pos: pos, name: name, value: value})
+	l.i += end + 1
+	return nil
+}
+
+func (l *lexer) bar() {
+	pos := l.pos()
+	start := l.i
+	for l.i < len(l.text) {
+		c := l.text[l.i]
+		if c == '|' || c == ':' || c == ']' || (c == '[' && l.i == start) {
+			l.i++
+			continue
+		}
+		break
+	}
+	t := l.text[start:l.i]
+	l.els = append(l.els, element{
+		kind:        kBar,
+		pos:         pos,
+		startRepeat: strings.HasSuffix(t, ":"),
+		endRepeat:   strings.HasPrefix(t, ":"),
+		thick:       strings.Contains(t, "||") || strings.ContainsAny(t, "[]"),
+	})
+	if isDigit(l.peek(0)) {
+		l.ending(l.pos())
+	}
+}
+
+// ending reads the numbers of an alternate ending like 1, 2, or 1,3
+func (l *lexer) ending(pos mml.Position) {
+	el := element{kind: kEnding, pos: pos}
+	for isDigit(l.peek(0)) {
+		el.endings = append(el.endings, l.number(0))
+		if l.peek(0) == ',' && isDigit(l.peek(1)) {
+			l.i++
+		}
+	}
+	l.els = append(l.els, el)
+}
+
+// tuplet reads a tuplet like (3 or (p:q:r, which plays the next r notes
+// in the time of q notes. If q is not specified, the usual default for p is
+// used.
+func (l *lexer) tuplet() {
+	pos := l.pos()
+	l.i++
+	p := l.number(3)
+	q, r := 0, p
+	if l.peek(0) == ':' {
+		l.i++
+		q = l.number(0)
+		if l.peek(0) == ':' {
+			l.i++
+			r = l.number(p)
+		}
+	}
+	if q == 0 {
+		switch p {
+		case 2, 4, 8:
+			q = 3
+		default:
+			q = 2
+		}
+	}
+	l.els = append(l.els, element{kind: kTuplet, pos: pos, p: p, q: q, r: r})
+}
+
+func (l *lexer) broken() {
+	pos := l.pos()
+	c := l.text[l.i]
+	n := 0
+	for l.peek(0) == c {
+		n++
+		l.i++
+	}
+	dir := 1
+	if c == '<' {
+		dir = -1
+	}
+	l.els = append(l.els, element{kind: kBroken, pos: pos, p: n, q: dir})
+}
+
+// applyBrokenRhythms lengthens and shortens the notes around each broken
+// rhythm symbol. A>B makes A dotted and halves B, while A<B does the opposite.
+// Each additional symbol halves the shorter note again.
+func applyBrokenRhythms(els []element) {
+	for k, el := range els {
+		if el.kind != kBroken {
+			continue
+		}
+		a, b := -1, -1
+		for i := k - 1; i >= 0; i-- {
+			if els[i].kind == kNote || els[i].kind == kRest {
+				a = i
+				break
+			}
+		}
+		for i := k + 1; i < len(els); i++ {
+			if els[i].kind == kNote || els[i].kind == kRest {
+				b = i
+				break
+			}
+		}
+		if a == -1 || b == -1 {
+			continue
+		}
+		short := 1.0
+		for i := 0; i < el.p; i++ {
+			short /= 2
+		}
+		long := 2 - short
+		if el.q < 0 {
+			long, short = short, long
+		}
+		els[a].length *= long
+		els[b].length *= short
+	}
+}
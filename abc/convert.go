@@ -0,0 +1,279 @@
+package abc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ff14wed/performgen/encoding"
+)
+
+var letterOffsets = map[byte]int{
+	'C': 0,
+	'D': 2,
+	'E': 4,
+	'F': 5,
+	'G': 7,
+	'A': 9,
+	'B': 11,
+}
+
+// letterFifths is the position of each natural note on the circle of fifths
+// relative to C
+var letterFifths = map[byte]int{
+	'F': -1,
+	'C': 0,
+	'G': 1,
+	'D': 2,
+	'A': 3,
+	'E': 4,
+	'B': 5,
+}
+
+// modeOffsets shifts the number of sharps in a key for each mode, relative
+// to the major key with the same tonic
+var modeOffsets = map[string]int{
+	"maj": 0,
+	"ion": 0,
+	"mix": -1,
+	"dor": -2,
+	"min": -3,
+	"aeo": -3,
+	"m":   -3,
+	"phr": -4,
+	"loc": -5,
+	"lyd": 1,
+}
+
+// key returns the accidental applied to each letter by a key signature like
+// "G", "Bbmin", "F# dorian", or "D ^c"
+func key(k string) (map[byte]int, error) {
+	accidentals := map[byte]int{}
+	fields := strings.Fields(k)
+	if len(fields) == 0 || fields[0] == "none" || fields[0] == "HP" || fields[0] == "Hp" {
+		return accidentals, nil
+	}
+	tonic := fields[0]
+	fifths, ok := letterFifths[byte(strings.ToUpper(tonic[:1])[0])]
+	if !ok {
+		// Keys like K:clef=treble only specify a clef
+		if strings.Contains(tonic, "=") {
+			return accidentals, nil
+		}
+		return nil, fmt.Errorf("invalid key: %s", k)
+	}
+	rest := tonic[1:]
+	if strings.HasPrefix(rest, "#") {
+		fifths += 7
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "b") {
+		fifths -= 7
+		rest = rest[1:]
+	}
+	extra := fields[1:]
+	if rest == "" && len(extra) > 0 && !strings.ContainsAny(extra[0], "^_=") {
+		rest = extra[0]
+		extra = extra[1:]
+	}
+	if rest != "" {
+		mode := strings.ToLower(rest)
+		if len(mode) > 3 {
+			mode = mode[:3]
+		}
+		offset, ok := modeOffsets[mode]
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %s", k)
+		}
+		fifths += offset
+	}
+	for i := 0; i < fifths && i < 7; i++ {
+		accidentals["FCGDAEB"[i]] = 1
+	}
+	for i := 0; i < -fifths && i < 7; i++ {
+		accidentals["BEADGCF"[i]] = -1
+	}
+	for _, f := range extra {
+		acc := 0
+		for len(f) > 0 && strings.IndexByte("^_=", f[0]) != -1 {
+			switch f[0] {
+			case '^':
+				acc++
+			case '_':
+				acc--
+			}
+			f = f[1:]
+		}
+		if len(f) == 1 {
+			if _, ok := letterOffsets[byte(strings.ToUpper(f)[0])]; ok {
+				accidentals[byte(strings.ToUpper(f)[0])] = acc
+			}
+		}
+	}
+	return accidentals, nil
+}
+
+type onset struct {
+	pos  float64
+	note encoding.Note
+}
+
+// Convert converts a tune to a sequence of perform steps. Repeats are
+// expanded, tied notes are merged into the first note of the tie, and the
+// notes of a chord are arpeggiated with encoding.ChordDelay milliseconds in
+// between each note.
+func Convert(t *Tune) (encoding.Sequence, error) {
+	seq, _, err := ConvertWithTempos(t)
+	return seq, err
+}
+
+// ConvertWithTempos converts a tune like Convert, and also returns the tempo
+// changes of the tune with their positions in quarter notes
+func ConvertWithTempos(t *Tune) (encoding.Sequence, []encoding.TempoChange, error) {
+	els, err := tokenize(t.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyAccidentals, err := key(t.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	unit, err := unitLength(t.UnitLength, t.Meter)
+	if err != nil {
+		return nil, nil, err
+	}
+	measure, err := meter(t.Meter)
+	if err != nil {
+		return nil, nil, err
+	}
+	msPerWhole, err := tempo(t.Tempo, unit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		onsets       []onset
+		tempos       = []encoding.TempoChange{{Beat: 0, BPM: 60000 / msPerWhole}}
+		pos          float64
+		barAcc       = map[pitch]int{}
+		tied         = map[int]bool{}
+		tupletLeft   int
+		tupletFactor float64
+	)
+	for _, el := range expand(els) {
+		switch el.kind {
+		case kField:
+			switch el.name {
+			case 'K':
+				keyAccidentals, err = key(el.value)
+			case 'L':
+				unit, err = fraction(el.value)
+			case 'M':
+				measure, err = meter(el.value)
+			case 'Q':
+				msPerWhole, err = tempo(el.value, unit)
+				tempos = append(tempos, encoding.TempoChange{Beat: pos, BPM: 60000 / msPerWhole})
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s at %s", err, el.pos)
+			}
+		case kBar, kEnding:
+			barAcc = map[pitch]int{}
+		case kTuplet:
+			tupletLeft = el.r
+			tupletFactor = float64(el.q) / float64(el.p)
+		case kRest, kNote:
+			length := el.length * unit
+			if el.kind == kRest && el.bars > 0 {
+				length = float64(el.bars) * measure
+			}
+			if tupletLeft > 0 {
+				length *= tupletFactor
+				tupletLeft--
+			}
+			nextTied := map[int]bool{}
+			for _, p := range el.pitches {
+				natural := pitch{letter: p.letter, octave: p.octave}
+				acc, ok := barAcc[natural]
+				switch {
+				case p.explicit:
+					acc = p.accidental
+					barAcc[natural] = acc
+				case !ok:
+					acc = keyAccidentals[p.letter]
+				}
+				midi := 12*(p.octave+1) + letterOffsets[p.letter] + acc
+				id := midi - 47
+				if id < 1 || id > 37 {
+					return nil, nil, fmt.Errorf("note out of range at %s", el.pos)
+				}
+				if !tied[midi] {
+					onsets = append(onsets, onset{pos: pos, note: encoding.Note(id)})
+				}
+				if el.tie {
+					nextTied[midi] = true
+				}
+			}
+			tied = nextTied
+			pos += length
+		}
+	}
+
+	sort.SliceStable(tempos, func(i, j int) bool { return tempos[i].Beat < tempos[j].Beat })
+	notes := make([]encoding.TimedNote, len(onsets))
+	for i, o := range onsets {
+		notes[i] = encoding.TimedNote{Note: o.note, At: encoding.TimeAt(tempos, o.pos)}
+	}
+	quarters := make([]encoding.TempoChange, len(tempos))
+	for i, t := range tempos {
+		quarters[i] = encoding.TempoChange{Beat: t.Beat * 4, BPM: t.BPM * 4}
+	}
+	return encoding.Arrange(notes, encoding.TimeAt(tempos, pos)), quarters, nil
+}
+
+// expand returns the elements in the order they are played after expanding
+// repeats and alternate endings. A :| without a matching |: repeats from the
+// start of the tune or the last double bar line.
+func expand(els []element) []element {
+	var (
+		out      []element
+		start    = 0
+		pass     = 1
+		skipping = false
+	)
+	for i := 0; i < len(els); i++ {
+		el := els[i]
+		switch el.kind {
+		case kEnding:
+			skipping = true
+			for _, n := range el.endings {
+				if n == pass {
+					skipping = false
+				}
+			}
+		case kBar:
+			if skipping && !el.thick && !el.startRepeat {
+				continue
+			}
+			skipping = false
+			out = append(out, el)
+			if el.endRepeat {
+				if pass < 2 {
+					pass++
+					i = start - 1
+					continue
+				}
+				pass = 1
+				start = i + 1
+			}
+			if el.startRepeat || el.thick {
+				pass = 1
+				start = i + 1
+			}
+			continue
+		}
+		if !skipping {
+			out = append(out, el)
+		}
+	}
+	return out
+}
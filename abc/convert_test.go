@@ -0,0 +1,108 @@
+package abc_test
+
+import (
+	"strings"
+
+	"github.com/ff14wed/performgen/abc"
+	"github.com/ff14wed/performgen/encoding"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func convert(input string) (encoding.Sequence, error) {
+	tunes, err := abc.Parse(strings.NewReader(input))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(tunes).To(HaveLen(1))
+	return abc.Convert(tunes[0])
+}
+
+// notes returns the note IDs and the time in milliseconds at which each
+// note is played
+func notes(seq encoding.Sequence) [][2]int {
+	var out [][2]int
+	for _, n := range seq.Timeline() {
+		out = append(out, [2]int{int(n.Note), int(n.At.Milliseconds())})
+	}
+	return out
+}
+
+var _ = Describe("Convert", func() {
+	It("plays notes with the unit length and tempo", func() {
+		seq, err := convert("X:1\nL:1/4\nQ:1/4=120\nK:C\nC, C c2 z c'/2 z/\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{1, 0}, {13, 500}, {25, 1000}, {37, 2500}}))
+		Expect(seq.Length().Milliseconds()).To(Equal(int64(3000)))
+	})
+	It("applies the key signature and propagates accidentals within a bar", func() {
+		seq, err := convert("X:1\nL:1/4\nK:Bb\nB E ^E E | E =B B\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{
+			{23, 0}, {16, 500}, {18, 1000}, {18, 1500},
+			{16, 2000}, {24, 2500}, {24, 3000},
+		}))
+	})
+	It("supports modes and explicit key accidentals", func() {
+		seq, err := convert("X:1\nL:1/4\nK:A dorian ^c\nF G C\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{19, 0}, {20, 500}, {14, 1000}}))
+	})
+	It("applies broken rhythms", func() {
+		seq, err := convert("X:1\nL:1/4\nK:C\nC>D E<F G>>A\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{
+			{13, 0}, {15, 750}, {17, 1000}, {18, 1250},
+			{20, 2000}, {22, 2875},
+		}))
+	})
+	It("arpeggiates chords and merges ties", func() {
+		seq, err := convert("X:1\nL:1/4\nK:C\n[CEG]2 G-|G C\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{13, 0}, {17, 20}, {20, 40}, {20, 1000}, {13, 2000}}))
+	})
+	It("plays triplets in the time of two notes", func() {
+		seq, err := convert("X:1\nL:1/8\nQ:1/4=60\nK:C\n(3CDE F\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{13, 0}, {15, 333}, {17, 667}, {18, 1000}}))
+	})
+	It("expands repeats and alternate endings", func() {
+		seq, err := convert("X:1\nL:1/4\nQ:1/4=240\nK:C\nC|:D|1E:|2F|]G\n")
+		Expect(err).ToNot(HaveOccurred())
+		var ids []int
+		for _, n := range notes(seq) {
+			ids = append(ids, n[0])
+		}
+		Expect(ids).To(Equal([]int{13, 15, 17, 15, 18, 20}))
+	})
+	It("applies inline tempo and key changes", func() {
+		seq, err := convert("X:1\nL:1/4\nK:C\nF [Q:1/4=60] F\nK:G\nF\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{18, 0}, {18, 500}, {19, 1500}}))
+	})
+	It("returns the tempo changes in quarter notes", func() {
+		tunes, err := abc.Parse(strings.NewReader("X:1\nL:1/4\nK:C\nF [Q:1/4=60] F\n"))
+		Expect(err).ToNot(HaveOccurred())
+		_, tempos, err := abc.ConvertWithTempos(tunes[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tempos).To(Equal([]encoding.TempoChange{{Beat: 0, BPM: 120}, {Beat: 1, BPM: 60}}))
+	})
+	It("ignores decorations, annotations, and grace notes", func() {
+		seq, err := convert("X:1\nL:1/4\nK:C\n\"Am\"!trill!{g}A .B ~c\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notes(seq)).To(Equal([][2]int{{22, 0}, {24, 500}, {25, 1000}}))
+	})
+	It("errors on notes out of range", func() {
+		_, err := convert("X:1\nK:C\nC c'' \n")
+		Expect(err).To(MatchError("note out of range at line 3, column 3"))
+	})
+	It("errors on note lengths divided by 0", func() {
+		_, err := convert("X:1\nK:C\nC A/0 B\n")
+		Expect(err).To(MatchError("invalid note length: division by 0 at line 3, column 4"))
+		_, err = convert("X:1\nK:C\n[CE]/0\n")
+		Expect(err).To(MatchError("invalid note length: division by 0 at line 3, column 5"))
+	})
+	It("errors on invalid symbols", func() {
+		_, err := convert("X:1\nK:C\nC & D\n")
+		Expect(err).To(MatchError("invalid symbol '&' at line 3, column 3"))
+	})
+})
@@ -0,0 +1,229 @@
+package abc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tune is a single tune of an ABC file
+type Tune struct {
+	// Number is the reference number from the X: field
+	Number int
+	// Title is the first title from the T: field
+	Title string
+	// Meter is the time signature from the M: field, like "6/8" or "C"
+	Meter string
+	// UnitLength is the unit note length from the L: field, like "1/8"
+	UnitLength string
+	// Tempo is the tempo from the Q: field, like "1/4=120"
+	Tempo string
+	// Key is the key from the K: field, like "Bb" or "Ador"
+	Key string
+
+	// Body contains the lines of music following the header
+	Body []Line
+}
+
+// Line is a single line of the tune body along with its line number in the
+// file
+type Line struct {
+	Text   string
+	Number int
+}
+
+// Parse reads all of the tunes in an ABC file. Tunes start with an X: field
+// and their header ends with the K: field.
+func Parse(r io.Reader) ([]*Tune, error) {
+	var (
+		tunes    []*Tune
+		tune     *Tune
+		inHeader bool
+		lineNum  int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			// A blank line ends the tune
+			tune = nil
+			continue
+		}
+		text := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
+		}
+		name, value, isField := field(trimmed)
+		if isField && name == 'X' {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference number '%s' at line %d", value, lineNum)
+			}
+			tune = &Tune{Number: n}
+			tunes = append(tunes, tune)
+			inHeader = true
+			continue
+		}
+		if tune == nil {
+			// Free text and file headers outside of a tune are ignored
+			continue
+		}
+		if inHeader {
+			if !isField {
+				return nil, fmt.Errorf("expected header field at line %d", lineNum)
+			}
+			switch name {
+			case 'T':
+				if tune.Title == "" {
+					tune.Title = value
+				}
+			case 'M':
+				tune.Meter = value
+			case 'L':
+				tune.UnitLength = value
+			case 'Q':
+				tune.Tempo = value
+			case 'K':
+				tune.Key = value
+				inHeader = false
+			}
+			continue
+		}
+		tune.Body = append(tune.Body, Line{Text: text, Number: lineNum})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, t := range tunes {
+		if t.Key == "" {
+			return nil, fmt.Errorf("tune %d has no K: field", t.Number)
+		}
+	}
+	return tunes, nil
+}
+
+// Find returns the tune with the given reference number
+func Find(tunes []*Tune, number int) (*Tune, error) {
+	for _, t := range tunes {
+		if t.Number == number {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("tune not found: X:%d", number)
+}
+
+// stripComment removes a % comment from the line. A \% is a literal %.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '%' {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// field returns the name and value of a field line like "T:Title"
+func field(line string) (byte, string, bool) {
+	if len(line) < 2 || line[1] != ':' {
+		return 0, "", false
+	}
+	c := line[0]
+	if !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') {
+		return 0, "", false
+	}
+	return c, strings.TrimSpace(line[2:]), true
+}
+
+// fraction parses a fraction like "1/8" or a whole number like "2"
+func fraction(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.SplitN(s, "/", 2)
+	num, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid fraction: %s", s)
+	}
+	den := 1
+	if len(parts) == 2 {
+		den, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || den == 0 {
+			return 0, fmt.Errorf("invalid fraction: %s", s)
+		}
+	}
+	return float64(num) / float64(den), nil
+}
+
+// meter returns the length of a measure in whole notes, or 0 for free meter
+func meter(m string) (float64, error) {
+	switch strings.TrimSpace(m) {
+	case "", "none":
+		return 0, nil
+	case "C", "C|":
+		return 1, nil
+	}
+	return fraction(m)
+}
+
+// unitLength returns the unit note length of a tune in whole notes. If the L:
+// field is missing, the default depends on the meter.
+func unitLength(l, m string) (float64, error) {
+	if l != "" {
+		return fraction(l)
+	}
+	length, err := meter(m)
+	if err != nil {
+		return 0, err
+	}
+	if length > 0 && length < 0.75 {
+		return 1.0 / 16, nil
+	}
+	return 1.0 / 8, nil
+}
+
+// tempo returns the number of milliseconds per whole note specified by a Q:
+// field like "1/4=120", "3/8=40", or `"Allegro" 1/4=120`. An old style tempo
+// with only a number counts unit lengths per minute.
+func tempo(q string, unit float64) (float64, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return 60000.0 * 4 / 120, nil
+	}
+	// Drop any quoted text
+	for {
+		start := strings.IndexByte(q, '"')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(q[start+1:], '"')
+		if end == -1 {
+			q = q[:start]
+			break
+		}
+		q = q[:start] + q[start+end+2:]
+	}
+	q = strings.TrimSpace(q)
+	beat := unit
+	bpmString := q
+	if idx := strings.IndexByte(q, '='); idx != -1 {
+		beat = 0
+		for _, f := range strings.Fields(q[:idx]) {
+			b, err := fraction(f)
+			if err != nil {
+				return 0, err
+			}
+			beat += b
+		}
+		bpmString = q[idx+1:]
+	}
+	bpm, err := strconv.ParseFloat(strings.TrimSpace(bpmString), 64)
+	if err != nil || bpm <= 0 || beat <= 0 {
+		return 0, fmt.Errorf("invalid tempo: %s", q)
+	}
+	return 60000.0 / (bpm * beat), nil
+}
@@ -0,0 +1,70 @@
+package abc_test
+
+import (
+	"strings"
+
+	"github.com/ff14wed/performgen/abc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tune", func() {
+	Describe("Parse", func() {
+		It("reads the header fields and body of each tune", func() {
+			tunes, err := abc.Parse(strings.NewReader(`%abc-2.1
+This is free text before the first tune
+
+X:1
+T:The Kesh
+T:The Kesh Jig
+M:6/8
+L:1/8
+Q:3/8=110
+K:G % comment
+|:GAG GAB|ABA ABd:|
+
+X:2
+T:Second
+K:Ador
+EAA
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tunes).To(Equal([]*abc.Tune{
+				{
+					Number:     1,
+					Title:      "The Kesh",
+					Meter:      "6/8",
+					UnitLength: "1/8",
+					Tempo:      "3/8=110",
+					Key:        "G",
+					Body:       []abc.Line{{Text: "|:GAG GAB|ABA ABd:|", Number: 11}},
+				},
+				{
+					Number: 2,
+					Title:  "Second",
+					Key:    "Ador",
+					Body:   []abc.Line{{Text: "EAA", Number: 16}},
+				},
+			}))
+		})
+		It("errors if a tune has no key field", func() {
+			_, err := abc.Parse(strings.NewReader("X:3\nT:Keyless\n"))
+			Expect(err).To(MatchError("tune 3 has no K: field"))
+		})
+		It("errors if the reference number is invalid", func() {
+			_, err := abc.Parse(strings.NewReader("X:three\nK:C\n"))
+			Expect(err).To(MatchError("invalid reference number 'three' at line 1"))
+		})
+	})
+	Describe("Find", func() {
+		It("finds a tune by its reference number", func() {
+			tunes := []*abc.Tune{{Number: 1}, {Number: 4}}
+			tune, err := abc.Find(tunes, 4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tune).To(BeIdenticalTo(tunes[1]))
+			_, err = abc.Find(tunes, 2)
+			Expect(err).To(MatchError("tune not found: X:2"))
+		})
+	})
+})
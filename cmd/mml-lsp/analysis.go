@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// command is a parsed command along with where it is in the document and
+// what it played
+type command struct {
+	cmd   mml.Command
+	span  mml.Span
+	start time.Duration
+	steps encoding.Sequence
+}
+
+// document is the result of parsing and executing the text of a document
+type document struct {
+	text        string
+	dialect     *mml.Dialect
+	commands    []command
+	comments    []mml.Comment
+	diagnostics []diagnostic
+	// parsed is false if parsing stopped at a syntax error
+	parsed bool
+}
+
+// analyze parses and executes the text of a document. Parsing stops at the
+// first syntax error, but the commands that fail to execute are skipped so
+// that all of them can be reported.
+func analyze(text string, d *mml.Dialect) *document {
+	doc := &document{text: text, dialect: d, diagnostics: []diagnostic{}}
+	parser := mml.NewDialectParser(bytes.NewReader([]byte(text)), d)
+	state := &mml.State{Dialect: d}
+	for {
+		cmd, span, err := parser.Next()
+		if err != nil {
+			doc.diagnostics = append(doc.diagnostics, parseDiagnostic(err))
+			return doc
+		}
+		if cmd == nil {
+			break
+		}
+		emitted, start := len(state.Sequence), state.Elapsed
+		if err := cmd.Execute(state); err != nil {
+			doc.diagnostics = append(doc.diagnostics, diagnostic{
+				Range:    toRange(span),
+				Severity: severityError,
+				Source:   "performgen",
+				Message:  err.Error(),
+			})
+		}
+		steps := state.Sequence[emitted:]
+		doc.commands = append(doc.commands, command{cmd: cmd, span: span, start: start, steps: steps})
+	}
+	doc.comments = parser.Comments()
+	doc.parsed = true
+	return doc
+}
+
+func parseDiagnostic(err error) diagnostic {
+	d := diagnostic{Severity: severityError, Source: "performgen", Message: err.Error()}
+	var parseErr *mml.ParseError
+	if errors.As(err, &parseErr) {
+		start := toPosition(parseErr.Position)
+		end := start
+		end.Character += utf8.RuneCountInString(parseErr.Token)
+		d.Range = textRange{Start: start, End: end}
+	}
+	return d
+}
+
+// commandAt returns the command at the position, or nil if there isn't one
+func (doc *document) commandAt(p position) *command {
+	pos := fromPosition(p)
+	for i := range doc.commands {
+		c := &doc.commands[i]
+		if !before(pos, c.span.Start) && before(pos, c.span.End) {
+			return c
+		}
+	}
+	return nil
+}
+
+// hover returns the description of what the command plays, or an empty
+// string if the command doesn't play anything
+func (c *command) hover() string {
+	if len(c.steps) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, step := range c.steps {
+		if note, ok := step.(encoding.Note); ok {
+			lines = append(lines, fmt.Sprintf("**%s** (in-game key `%s`)", noteName(note), note.Label()))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "**Rest**")
+	}
+	lines = append(lines, fmt.Sprintf("Starts at %dms and lasts %dms",
+		c.start/time.Millisecond, c.steps.Length()/time.Millisecond))
+	return strings.Join(lines, "\n\n")
+}
+
+// noteName returns the scientific pitch name of a note, like "C#5" for the
+// in-game key "C# (+1)"
+func noteName(n encoding.Note) string {
+	label := n.Label()
+	if label == "" {
+		return fmt.Sprintf("note %d", n)
+	}
+	// Perform note IDs are 47 less than MIDI note numbers
+	midi := int(n) + 47
+	return fmt.Sprintf("%s%d", strings.Fields(label)[0], midi/12-1)
+}
+
+// format returns the text of the document printed by mml.Print
+func (doc *document) format() (string, error) {
+	ast := &mml.AST{Comments: doc.comments}
+	for _, c := range doc.commands {
+		ast.Sequence = append(ast.Sequence, c.cmd)
+		ast.Spans = append(ast.Spans, c.span)
+	}
+	buf := new(bytes.Buffer)
+	err := mml.Print(buf, ast, mml.PrintOptions{Dialect: doc.dialect})
+	return buf.String(), err
+}
+
+// before returns true if a is before b in the document
+func before(a, b mml.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// toPosition converts a position of the parser, which counts lines and
+// columns from 1, to a position of the protocol, which counts from 0. Columns
+// are counted in characters, which is the same as the UTF-16 code units of
+// the protocol for the characters used in MML.
+func toPosition(p mml.Position) position {
+	return position{Line: p.Line - 1, Character: p.Column - 1}
+}
+
+func fromPosition(p position) mml.Position {
+	return mml.Position{Line: p.Line + 1, Column: p.Character + 1}
+}
+
+func toRange(s mml.Span) textRange {
+	return textRange{Start: toPosition(s.Start), End: toPosition(s.End)}
+}
+
+// endPosition returns the position of the end of the text
+func endPosition(text string) position {
+	line := strings.Count(text, "\n")
+	last := text[strings.LastIndex(text, "\n")+1:]
+	return position{Line: line, Character: utf8.RuneCountInString(last)}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ff14wed/performgen/mml"
+)
+
+func main() {
+	dialectName := flag.String("dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	flag.Parse()
+
+	dialect, err := mml.LookupDialect(*dialectName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if err := newServer(os.Stdin, os.Stdout, dialect).run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
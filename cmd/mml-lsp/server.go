@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ff14wed/performgen/mml"
+)
+
+// server is a language server that reads messages from in and writes
+// responses and notifications to out
+type server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	dialect  *mml.Dialect
+	docs     map[string]*document
+	shutdown bool
+}
+
+func newServer(in io.Reader, out io.Writer, d *mml.Dialect) *server {
+	return &server{
+		in:      bufio.NewReader(in),
+		out:     out,
+		dialect: d,
+		docs:    make(map[string]*document),
+	}
+}
+
+// run handles messages until the exit notification or the end of the input.
+// It returns an error if the client exits without shutting the server down
+// first.
+func (s *server) run() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.respond(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, respErr := s.handle(req)
+		if req.ID == nil {
+			// Notifications don't have a response
+			continue
+		}
+		if err := s.respond(req.ID, result, respErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) respond(id *json.RawMessage, result interface{}, respErr *responseError) error {
+	resp := response{JSONRPC: "2.0", ID: id, Error: respErr}
+	if respErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = data
+	}
+	return writeMessage(s.out, resp)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle returns the result of a request, or handles a notification
+func (s *server) handle(req request) (interface{}, *responseError) {
+	switch req.Method {
+	case "initialize":
+		result := initializeResult{Capabilities: serverCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			HoverProvider:              true,
+			DocumentFormattingProvider: true,
+		}}
+		result.ServerInfo.Name = "mml-lsp"
+		return result, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			// The server asks for full syncs, so the last change is the whole text
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publishDiagnostics(params.TextDocument.URI, []diagnostic{})
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.format(params)
+	}
+	if req.ID == nil {
+		// Unknown notifications like $/cancelRequest can be ignored
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+func unmarshalParams(req request, v interface{}) *responseError {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update analyzes the new text of a document and publishes its diagnostics
+func (s *server) update(uri, text string) *responseError {
+	doc := analyze(text, s.dialect)
+	s.docs[uri] = doc
+	return s.publishDiagnostics(uri, doc.diagnostics)
+}
+
+func (s *server) publishDiagnostics(uri string, diagnostics []diagnostic) *responseError {
+	err := s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	if err != nil {
+		return &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+// hover returns the description of the command under the cursor, or nil if
+// there is nothing to describe
+func (s *server) hover(params textDocumentPositionParams) *hover {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	c := doc.commandAt(params.Position)
+	if c == nil {
+		return nil
+	}
+	text := c.hover()
+	if text == "" {
+		return nil
+	}
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    toRange(c.span),
+	}
+}
+
+// format returns the edit that replaces the document with its formatted
+// text. Documents with syntax errors aren't formatted.
+func (s *server) format(params documentFormattingParams) ([]textEdit, *responseError) {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok || !doc.parsed {
+		return nil, nil
+	}
+	formatted, err := doc.format()
+	if err != nil {
+		return nil, &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	if formatted == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   textRange{End: endPosition(doc.text)},
+		NewText: formatted,
+	}}, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// client is an in-process language client connected to a server with pipes
+type client struct {
+	in            *io.PipeWriter
+	out           *bufio.Reader
+	nextID        int
+	notifications []notificationMessage
+}
+
+type notificationMessage struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type responseMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// read returns the next message written by the server
+func (c *client) read() responseMessage {
+	body, err := readMessage(c.out)
+	Expect(err).ToNot(HaveOccurred())
+	var msg responseMessage
+	Expect(json.Unmarshal(body, &msg)).To(Succeed())
+	return msg
+}
+
+// call sends a request and returns its response, keeping the notifications
+// that were written before the response
+func (c *client) call(method string, params interface{}) responseMessage {
+	c.nextID++
+	Expect(writeMessage(c.in, map[string]interface{}{
+		"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params,
+	})).To(Succeed())
+	for {
+		msg := c.read()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, notificationMessage{Method: msg.Method, Params: msg.Params})
+			continue
+		}
+		Expect(*msg.ID).To(Equal(c.nextID))
+		return msg
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	Expect(writeMessage(c.in, map[string]interface{}{
+		"jsonrpc": "2.0", "method": method, "params": params,
+	})).To(Succeed())
+}
+
+// diagnostics returns the next diagnostics published by the server
+func (c *client) diagnostics() publishDiagnosticsParams {
+	var msg notificationMessage
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		m := c.read()
+		msg = notificationMessage{Method: m.Method, Params: m.Params}
+	}
+	Expect(msg.Method).To(Equal("textDocument/publishDiagnostics"))
+	var params publishDiagnosticsParams
+	Expect(json.Unmarshal(msg.Params, &params)).To(Succeed())
+	return params
+}
+
+var _ = Describe("Server", func() {
+	const uri = "file:///song.mml"
+	var (
+		c    *client
+		done chan error
+	)
+	BeforeEach(func() {
+		clientIn, serverIn := io.Pipe()
+		serverOut, clientOut := io.Pipe()
+		c = &client{in: serverIn, out: bufio.NewReader(serverOut)}
+		done = make(chan error, 1)
+		go func() {
+			done <- newServer(clientIn, clientOut, mml.Performgen).run()
+			clientOut.Close()
+		}()
+
+		resp := c.call("initialize", map[string]interface{}{})
+		Expect(resp.Error).To(BeNil())
+		var result initializeResult
+		Expect(json.Unmarshal(resp.Result, &result)).To(Succeed())
+		Expect(result.Capabilities).To(Equal(serverCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			HoverProvider:              true,
+			DocumentFormattingProvider: true,
+		}))
+		c.notify("initialized", map[string]interface{}{})
+	})
+	AfterEach(func() {
+		resp := c.call("shutdown", nil)
+		Expect(resp.Error).To(BeNil())
+		c.notify("exit", nil)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+	open := func(text string) {
+		c.notify("textDocument/didOpen", didOpenParams{
+			TextDocument: textDocumentItem{URI: uri, LanguageID: "mml", Version: 1, Text: text},
+		})
+	}
+	change := func(text string) {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+	}
+	rng := func(startLine, startChar, endLine, endChar int) textRange {
+		return textRange{
+			Start: position{Line: startLine, Character: startChar},
+			End:   position{Line: endLine, Character: endChar},
+		}
+	}
+
+	Describe("diagnostics", func() {
+		It("publishes the commands that fail to execute", func() {
+			open("o6c d\nt0 c")
+			Expect(c.diagnostics()).To(Equal(publishDiagnosticsParams{URI: uri, Diagnostics: []diagnostic{
+				{Range: rng(0, 4, 0, 5), Severity: severityError, Source: "performgen", Message: "invalid note: d at octave 6"},
+				{Range: rng(1, 0, 1, 2), Severity: severityError, Source: "performgen", Message: "cannot set tempo to lower than 1"},
+			}}))
+		})
+		It("publishes syntax errors and updates them when the document changes", func() {
+			open("c H d")
+			Expect(c.diagnostics().Diagnostics).To(Equal([]diagnostic{
+				{Range: rng(0, 2, 0, 3), Severity: severityError, Source: "performgen", Message: "invalid token 'H' at line 1, column 3"},
+			}))
+			change("c e d")
+			Expect(c.diagnostics().Diagnostics).To(BeEmpty())
+		})
+	})
+
+	Describe("hover", func() {
+		hoverAt := func(line, character int) json.RawMessage {
+			resp := c.call("textDocument/hover", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+				Position:     position{Line: line, Character: character},
+			})
+			Expect(resp.Error).To(BeNil())
+			return resp.Result
+		}
+		BeforeEach(func() {
+			open("t120 o5 c+8\nr4 d")
+			c.diagnostics()
+		})
+		It("shows the note name, in-game key, and timing of a note", func() {
+			var result hover
+			Expect(json.Unmarshal(hoverAt(0, 10), &result)).To(Succeed())
+			Expect(result).To(Equal(hover{
+				Contents: markupContent{
+					Kind:  "markdown",
+					Value: "**C#5** (in-game key `C# (+1)`)\n\nStarts at 0ms and lasts 250ms",
+				},
+				Range: rng(0, 8, 0, 11),
+			}))
+		})
+		It("shows the timing of a rest", func() {
+			var result hover
+			Expect(json.Unmarshal(hoverAt(1, 0), &result)).To(Succeed())
+			Expect(result.Contents.Value).To(Equal("**Rest**\n\nStarts at 250ms and lasts 500ms"))
+		})
+		It("shows nothing for commands that don't play anything", func() {
+			Expect(string(hoverAt(0, 1))).To(Equal("null"))
+			Expect(string(hoverAt(0, 4))).To(Equal("null"))
+		})
+	})
+
+	Describe("formatting", func() {
+		format := func() []textEdit {
+			resp := c.call("textDocument/formatting", documentFormattingParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+			})
+			Expect(resp.Error).To(BeNil())
+			var edits []textEdit
+			Expect(json.Unmarshal(resp.Result, &edits)).To(Succeed())
+			return edits
+		}
+		It("prints the document with a line for each measure", func() {
+			open("T120 L8\n\n\n\nC+ 4 . D")
+			c.diagnostics()
+			Expect(format()).To(Equal([]textEdit{
+				{Range: rng(0, 0, 4, 8), NewText: "t120 l8 c+4.d\n"},
+			}))
+		})
+		It("keeps the comments of the document", func() {
+			open("/* intro */ T120 L8\nC+ 4 . /* dotted */\nD")
+			c.diagnostics()
+			Expect(format()).To(Equal([]textEdit{
+				{Range: rng(0, 0, 2, 1), NewText: "/* intro */ t120 l8 c+4. /* dotted */ d\n"},
+			}))
+		})
+		It("doesn't change documents that are already formatted", func() {
+			open("t120 l8 c+4.d\n")
+			c.diagnostics()
+			Expect(format()).To(BeEmpty())
+		})
+		It("doesn't format documents with syntax errors", func() {
+			open("C H")
+			c.diagnostics()
+			Expect(format()).To(BeNil())
+		})
+	})
+
+	It("returns an error for unknown requests", func() {
+		resp := c.call("textDocument/definition", textDocumentPositionParams{})
+		Expect(resp.Error).To(Equal(&responseError{Code: codeMethodNotFound, Message: "method not found: textDocument/definition"}))
+	})
+})
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	var (
+		addr string
+		c    config
+	)
+	flag.StringVar(&addr, "addr", ":8080", "address to listen on")
+	flag.IntVar(&c.maxInputSize, "max-input", 64*1024, "largest request body accepted, in bytes (0 for no limit)")
+	flag.IntVar(&c.maxNotes, "max-notes", 20000, "largest number of notes in a track (0 for no limit)")
+	flag.DurationVar(&c.maxDuration, "max-duration", 30*time.Minute, "longest duration of a track (0 for no limit)")
+	flag.IntVar(&c.maxSegments, "max-segments", 10000, "largest number of segments in a track (0 for no limit)")
+	flag.IntVar(&c.previewMaxNotes, "preview-max-notes", 2000, "largest number of notes in a track sent to /preview (0 for no limit)")
+	flag.DurationVar(&c.previewMaxDuration, "preview-max-duration", 5*time.Minute, "longest duration of a track sent to /preview (0 for no limit)")
+	flag.DurationVar(&c.timeout, "timeout", 10*time.Second, "longest time spent on a single request (0 for no limit)")
+	flag.Parse()
+
+	log.Printf("listening on %s", addr)
+	if err := http.ListenAndServe(addr, newServer(c)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
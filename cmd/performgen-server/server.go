@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+	"github.com/ff14wed/performgen/preview"
+)
+
+// config holds the limits applied to every request
+type config struct {
+	maxInputSize int
+	maxNotes     int
+	maxDuration  time.Duration
+	maxSegments  int
+	timeout      time.Duration
+	// previewMaxNotes and previewMaxDuration replace maxNotes and maxDuration
+	// for previews, which take much longer to render than segments
+	previewMaxNotes    int
+	previewMaxDuration time.Duration
+}
+
+type server struct {
+	config config
+	mux    *http.ServeMux
+}
+
+// newServer returns the handler for all of the endpoints of the API
+func newServer(c config) http.Handler {
+	s := &server{config: c, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/generate", s.post(s.generate))
+	s.mux.HandleFunc("/lint", s.post(s.lint))
+	s.mux.HandleFunc("/preview", s.post(s.preview))
+	return s.mux
+}
+
+// request is the MML and options read from a request
+type request struct {
+	ctx     context.Context
+	input   string
+	dialect *mml.Dialect
+	format  string
+}
+
+// post only allows POST requests to the handler, and reads the MML from the
+// request body and the dialect and format from the `dialect` and `format`
+// query parameters
+func (s *server) post(handler func(w http.ResponseWriter, req request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		body := io.Reader(r.Body)
+		if s.config.maxInputSize > 0 {
+			// Read one more byte than the limit so that GenerateContext can tell
+			// that the input is too large
+			body = io.LimitReader(r.Body, int64(s.config.maxInputSize)+1)
+		}
+		input, err := ioutil.ReadAll(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		dialect := mml.Performgen
+		if name := r.URL.Query().Get("dialect"); name != "" {
+			if dialect, err = mml.LookupDialect(name); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		ctx := r.Context()
+		if s.config.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.config.timeout)
+			defer cancel()
+		}
+		handler(w, request{
+			ctx:     ctx,
+			input:   string(input),
+			dialect: dialect,
+			format:  r.URL.Query().Get("format"),
+		})
+	}
+}
+
+func (s *server) generateSegments(req request) ([]encoding.PerformSegment, error) {
+	return performgen.GenerateContext(req.ctx, req.input, s.options(req))
+}
+
+// options returns the generation options of a request with the limits of
+// the server
+func (s *server) options(req request) performgen.Options {
+	return performgen.Options{
+		Dialect:      req.dialect,
+		MaxInputSize: s.config.maxInputSize,
+		MaxNotes:     s.config.maxNotes,
+		MaxDuration:  s.config.maxDuration,
+		MaxSegments:  s.config.maxSegments,
+	}
+}
+
+type segmentJSON struct {
+	Data     string `json:"data"`
+	Duration int64  `json:"duration"`
+}
+
+// generate writes the segments of the MML in the format given by the
+// `format` query parameter: json (default), csv, or binary. The binary format
+// is each 32 byte block followed by its duration in milliseconds as a 32-bit
+// little endian integer.
+func (s *server) generate(w http.ResponseWriter, req request) {
+	switch req.format {
+	case "", "json", "csv", "binary":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format: %s", req.format))
+		return
+	}
+	segments, err := s.generateSegments(req)
+	if err != nil {
+		writeGenerateError(w, err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	switch req.format {
+	case "", "json":
+		resp := struct {
+			Segments []segmentJSON `json:"segments"`
+		}{Segments: []segmentJSON{}}
+		for _, segment := range segments {
+			resp.Segments = append(resp.Segments, segmentJSON{
+				Data:     segment.Block.String(),
+				Duration: int64(segment.Length / time.Millisecond),
+			})
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	case "csv":
+		buf.WriteString("data,duration(ms)\n")
+		for _, segment := range segments {
+			fmt.Fprintf(buf, "%s,%d\n", segment.Block, segment.Length/time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/csv")
+	case "binary":
+		for _, segment := range segments {
+			buf.Write(segment.Block.Bytes())
+			_ = binary.Write(buf, binary.LittleEndian, uint32(segment.Length/time.Millisecond))
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+type diagnostic struct {
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Message   string `json:"message"`
+}
+
+// lint writes every problem found in the MML. Parsing stops at the first
+// syntax error, but the commands that fail to execute are skipped so that
+// all of them can be reported.
+func (s *server) lint(w http.ResponseWriter, req request) {
+	if s.config.maxInputSize > 0 && len(req.input) > s.config.maxInputSize {
+		writeGenerateError(w, &performgen.LimitError{Limit: performgen.LimitInputSize, Max: int64(s.config.maxInputSize)})
+		return
+	}
+	diagnostics := []diagnostic{}
+	parser := mml.NewDialectParser(bytes.NewReader([]byte(req.input)), req.dialect)
+	state := &mml.State{Dialect: req.dialect}
+	for {
+		if err := req.ctx.Err(); err != nil {
+			writeGenerateError(w, err)
+			return
+		}
+		cmd, span, err := parser.Next()
+		if err != nil {
+			d := diagnostic{Message: err.Error()}
+			var parseErr *mml.ParseError
+			if errors.As(err, &parseErr) {
+				d.Line, d.Column = parseErr.Position.Line, parseErr.Position.Column
+			}
+			diagnostics = append(diagnostics, d)
+			break
+		}
+		if cmd == nil {
+			break
+		}
+		if err := cmd.Execute(state); err != nil {
+			diagnostics = append(diagnostics, diagnostic{
+				Line:      span.Start.Line,
+				Column:    span.Start.Column,
+				EndLine:   span.End.Line,
+				EndColumn: span.End.Column,
+				Message:   err.Error(),
+			})
+		}
+		state.Sequence = state.Sequence[:0]
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Diagnostics []diagnostic `json:"diagnostics"`
+	}{diagnostics})
+}
+
+// preview writes the MML rendered as a WAV file
+func (s *server) preview(w http.ResponseWriter, req request) {
+	opts := s.options(req)
+	opts.MaxNotes = s.config.previewMaxNotes
+	opts.MaxDuration = s.config.previewMaxDuration
+	segments, err := performgen.GenerateContext(req.ctx, req.input, opts)
+	if err != nil {
+		writeGenerateError(w, err)
+		return
+	}
+	seq := encoding.Sequence{}
+	for _, segment := range segments {
+		seq = append(seq, segment.Block.Sequence()...)
+	}
+	buf := new(bytes.Buffer)
+	if err := preview.WriteWAV(req.ctx, buf, seq); err != nil {
+		writeGenerateError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/wav")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeGenerateError writes the error returned while generating a track with
+// the status code that matches the cause of the error
+func writeGenerateError(w http.ResponseWriter, err error) {
+	var limitErr *performgen.LimitError
+	switch {
+	case errors.As(err, &limitErr) && limitErr.Limit == performgen.LimitInputSize:
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	case errors.As(err, &limitErr):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeError(w, http.StatusServiceUnavailable, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
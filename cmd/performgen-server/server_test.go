@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		c      config
+		server *httptest.Server
+	)
+	BeforeEach(func() {
+		c = config{}
+	})
+	JustBeforeEach(func() {
+		server = httptest.NewServer(newServer(c))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+	post := func(path, body string) *http.Response {
+		resp, err := http.Post(server.URL+path, "text/plain", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+	decode := func(resp *http.Response, v interface{}) {
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(json.NewDecoder(resp.Body).Decode(v)).To(Succeed())
+	}
+	type errorResponse struct {
+		Error string `json:"error"`
+	}
+
+	Describe("POST /generate", func() {
+		It("writes the segments as JSON by default", func() {
+			resp := post("/generate", "t80o3a2b2c2d2e2f2g2")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var body struct {
+				Segments []struct {
+					Data     string `json:"data"`
+					Duration int    `json:"duration"`
+				} `json:"segments"`
+			}
+			decode(resp, &body)
+			Expect(body.Segments).To(HaveLen(4))
+			Expect(body.Segments[0].Data).To(Equal("1d0afffafffafffafffafffafffa0cfffafffafffafffafffafffa01fffa0000"))
+			Expect(body.Segments[0].Duration).To(Equal(3250))
+		})
+		It("writes the segments as CSV", func() {
+			resp := post("/generate?format=csv", "t80o3a2b2c2d2e2f2g2")
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("text/csv"))
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(HavePrefix("data,duration(ms)\n1d0afffafffafffafffafffafffa0cfffafffafffafffafffafffa01fffa0000,3250\n"))
+		})
+		It("writes the segments as binary blocks followed by their durations", func() {
+			resp := post("/generate?format=binary", "c")
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/octet-stream"))
+			data, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(HaveLen(36))
+			Expect(data[:5]).To(Equal([]byte{5, 13, 0xFF, 0xFA, 0xFF}))
+			Expect(binary.LittleEndian.Uint32(data[32:36])).To(Equal(uint32(500)))
+		})
+		It("uses the dialect query parameter", func() {
+			resp := post("/generate?dialect=mabinogi", "t300")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			var body errorResponse
+			decode(resp, &body)
+			Expect(body.Error).To(Equal("execution error at line 1, column 1: cannot set tempo to greater than 255"))
+		})
+		It("errors if the dialect is unknown", func() {
+			resp := post("/generate?dialect=foo", "c")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+		It("errors if the format is unknown", func() {
+			resp := post("/generate?format=xml", "c")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			var body errorResponse
+			decode(resp, &body)
+			Expect(body.Error).To(Equal("unknown format: xml"))
+		})
+		It("errors if the MML is invalid", func() {
+			resp := post("/generate", " HABCD")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			var body errorResponse
+			decode(resp, &body)
+			Expect(body.Error).To(Equal("invalid token 'H' at line 1, column 2"))
+		})
+		It("only allows POST requests", func() {
+			resp, err := http.Get(server.URL + "/generate")
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			Expect(resp.Header.Get("Allow")).To(Equal("POST"))
+		})
+		Context("with limits", func() {
+			BeforeEach(func() {
+				c = config{maxInputSize: 10, maxNotes: 3, maxDuration: time.Second}
+			})
+			It("errors if the input is too large", func() {
+				resp := post("/generate", "c c c c c c")
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+				var body errorResponse
+				decode(resp, &body)
+				Expect(body.Error).To(Equal("input is larger than the limit of 10 bytes"))
+			})
+			It("errors if the track exceeds a limit", func() {
+				resp := post("/generate", "l16cccc")
+				Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+				var body errorResponse
+				decode(resp, &body)
+				Expect(body.Error).To(Equal("track has more than the limit of 3 notes"))
+			})
+		})
+		Context("when the request times out", func() {
+			BeforeEach(func() {
+				c = config{timeout: time.Nanosecond}
+			})
+			It("errors", func() {
+				resp := post("/generate", "c")
+				Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+	})
+
+	Describe("POST /lint", func() {
+		type lintResponse struct {
+			Diagnostics []diagnostic `json:"diagnostics"`
+		}
+		It("returns no diagnostics for valid MML", func() {
+			resp := post("/lint", "cde")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var body lintResponse
+			decode(resp, &body)
+			Expect(body.Diagnostics).To(BeEmpty())
+		})
+		It("returns every command that fails to execute", func() {
+			resp := post("/lint", "o6c d\nt0 c")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var body lintResponse
+			decode(resp, &body)
+			Expect(body.Diagnostics).To(Equal([]diagnostic{
+				{Line: 1, Column: 5, EndLine: 1, EndColumn: 6, Message: "invalid note: d at octave 6"},
+				{Line: 2, Column: 1, EndLine: 2, EndColumn: 3, Message: "cannot set tempo to lower than 1"},
+			}))
+		})
+		It("stops at the first syntax error", func() {
+			resp := post("/lint", "o6d c H d")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var body lintResponse
+			decode(resp, &body)
+			Expect(body.Diagnostics).To(Equal([]diagnostic{
+				{Line: 1, Column: 3, EndLine: 1, EndColumn: 4, Message: "invalid note: d at octave 6"},
+				{Line: 1, Column: 7, Message: "invalid token 'H' at line 1, column 7"},
+			}))
+		})
+	})
+
+	Describe("POST /preview", func() {
+		It("writes a WAV file", func() {
+			resp := post("/preview", "c")
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("audio/wav"))
+			header, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(header[0:4])).To(Equal("RIFF"))
+			Expect(string(header[8:12])).To(Equal("WAVE"))
+		})
+		It("errors if the MML is invalid", func() {
+			resp := post("/preview", "o7")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+		Context("with limits", func() {
+			BeforeEach(func() {
+				c = config{maxNotes: 3, maxDuration: time.Minute, previewMaxNotes: 2, previewMaxDuration: time.Second}
+			})
+			It("errors if the track has more notes than the preview limit", func() {
+				resp := post("/preview", "l16ccc")
+				Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+				var body errorResponse
+				decode(resp, &body)
+				Expect(body.Error).To(Equal("track has more than the limit of 2 notes"))
+			})
+			It("errors if the track is longer than the preview limit", func() {
+				resp := post("/preview", "c1")
+				Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+			})
+			It("doesn't apply the preview limits to /generate", func() {
+				resp := post("/generate", "l16ccc")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+	})
+})
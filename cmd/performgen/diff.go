@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen/diff"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// runDiff writes the notes that were inserted, removed, or shifted between
+// two MML files
+func runDiff(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		dialectName string
+		beats       int
+	)
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.StringVar(&dialectName, "dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	flags.IntVar(&beats, "beats", 4, "number of quarter note beats in a measure")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return errors.New("diff requires an old and a new MML file")
+	}
+	if beats < 1 {
+		return errors.New("-beats must be at least 1")
+	}
+	dialect, err := mml.LookupDialect(dialectName)
+	if err != nil {
+		return err
+	}
+	var tracks [2][]diff.Note
+	for i, file := range flags.Args() {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if tracks[i], err = diff.Notes(string(input), dialect); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	}
+
+	for _, c := range diff.Compare(tracks[0], tracks[1]) {
+		var line string
+		switch c.Kind {
+		case diff.Inserted:
+			line = fmt.Sprintf("%s: inserted %s", notePosition(c.New[0], beats), noteLabels(c.New))
+		case diff.Removed:
+			line = fmt.Sprintf("%s: removed %s", notePosition(c.Old[0], beats), noteLabels(c.Old))
+		case diff.Shifted:
+			line = fmt.Sprintf("%s: shifted %s by %+dms", notePosition(c.Old[0], beats), noteLabels(c.Old), c.Shift()/time.Millisecond)
+		}
+		if _, err := fmt.Fprintln(stdout, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notePosition describes when a note is played, like
+// "measure 2, beat 1.5 (2250ms)"
+func notePosition(n diff.Note, beatsPerMeasure int) string {
+	measure, beat := n.Position(beatsPerMeasure)
+	return fmt.Sprintf("measure %d, beat %s (%dms)", measure,
+		strconv.FormatFloat(math.Round(beat*100)/100, 'f', -1, 64), n.At/time.Millisecond)
+}
+
+func noteLabels(notes []diff.Note) string {
+	labels := make([]string, len(notes))
+	for i, n := range notes {
+		labels[i] = n.Note.Label()
+	}
+	return strings.Join(labels, ", ")
+}
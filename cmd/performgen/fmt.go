@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// runFmt formats MML files, or MML read from stdin if there are no files
+func runFmt(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		dialectName string
+		write       bool
+		opts        mml.PrintOptions
+	)
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.StringVar(&dialectName, "dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	flags.BoolVar(&write, "w", false, "write the result to the files instead of stdout")
+	flags.BoolVar(&opts.BarNumbers, "bars", false, "add a comment with the bar number to the start of each line")
+	flags.IntVar(&opts.BeatsPerMeasure, "beats", 4, "number of quarter note beats in a measure")
+	_ = flags.Parse(args)
+
+	dialect, err := mml.LookupDialect(dialectName)
+	if err != nil {
+		return err
+	}
+	opts.Dialect = dialect
+
+	if flags.NArg() == 0 {
+		if write {
+			return errors.New("-w requires files to write to")
+		}
+		input, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		output, err := formatMML(string(input), opts)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(stdout, output)
+		return err
+	}
+	for _, file := range flags.Args() {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		output, err := formatMML(string(input), opts)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		if write {
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(file, []byte(output), info.Mode()); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.WriteString(stdout, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatMML prints the MML in the style of mml.Print. If the MML can be
+// generated, the formatted MML is checked to generate the same segments.
+func formatMML(input string, opts mml.PrintOptions) (string, error) {
+	ast, err := mml.NewDialectParser(bytes.NewReader([]byte(input)), opts.Dialect).Parse()
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := mml.Print(buf, ast, opts); err != nil {
+		return "", err
+	}
+	output := buf.String()
+
+	state, err := performgen.RunDialect(input, opts.Dialect)
+	if err != nil {
+		// There is nothing to compare against
+		return output, nil
+	}
+	formatted, err := performgen.RunDialect(output, opts.Dialect)
+	if err != nil || !sameSegments(state.Sequence.Segments(), formatted.Sequence.Segments()) {
+		return "", errors.New("formatting would change what the MML plays")
+	}
+	return output, nil
+}
+
+func sameSegments(a, b []encoding.PerformSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Length != b[i].Length || !bytes.Equal(a[i].Block.Bytes(), b[i].Block.Bytes()) {
+			return false
+		}
+	}
+	return true
+}
@@ -3,18 +3,111 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/abc"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/midi"
+	"github.com/ff14wed/performgen/mml"
+	"github.com/ff14wed/performgen/musicxml"
+	"github.com/ff14wed/performgen/textnote"
 )
 
+type options struct {
+	format       string
+	dialect      string
+	part         string
+	voice        string
+	tune         int
+	interval     time.Duration
+	tempo        int
+	output       string
+	stream       bool
+	instrument   string
+	concertPitch bool
+	fold         bool
+	protocol     string
+	minSpacing   time.Duration
+	optimize     bool
+	// maxLength and maxNotes limit each segment of CSV output
+	maxLength time.Duration
+	maxNotes  int
+	// from, to, measures, and beats select a section of an MML track
+	from     string
+	to       string
+	measures string
+	beats    int
+}
+
+// subcommands are run with `performgen <name> [flags] [files]` instead of
+// converting a score
+var subcommands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	"diff":  runDiff,
+	"fmt":   runFmt,
+	"repl":  runRepl,
+	"watch": runWatch,
+}
+
 func main() {
-	reader := bufio.NewReader(os.Stdin)
-	output, err := mainWithError(reader)
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var opts options
+	flag.StringVar(&opts.format, "format", "mml", "input format: mml, musicxml, abc, or text")
+	flag.StringVar(&opts.dialect, "dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	flag.StringVar(&opts.output, "output", "csv", "output format: csv, text, midi, or markers")
+	flag.StringVar(&opts.part, "part", "", "MusicXML part ID or name to convert (default: the first part)")
+	flag.StringVar(&opts.voice, "voice", "", "MusicXML voice to convert (default: all voices)")
+	flag.IntVar(&opts.tune, "tune", 0, "ABC reference number (X:) of the tune to convert (default: the first tune)")
+	flag.DurationVar(&opts.interval, "interval", 0, "text format: length of notes written without a length")
+	flag.IntVar(&opts.tempo, "tempo", 120, "text format: tempo in beats per minute of the MIDI file written with -output midi")
+	flag.StringVar(&opts.instrument, "instrument", "", "MML: name or number of the instrument that plays the track")
+	flag.BoolVar(&opts.concertPitch, "concert-pitch", false, "MML: notes are written at the pitch the instrument sounds instead of the key that plays them")
+	flag.BoolVar(&opts.fold, "fold", false, "MML: move notes out of the instrument's range by octaves until they're in range")
+	flag.DurationVar(&opts.minSpacing, "min-spacing", 0, "MML: shortest time between two notes, taken back from the rests after them")
+	flag.DurationVar(&opts.maxLength, "max-segment-length", 0, "CSV output: longest duration of a segment (default: no limit)")
+	flag.IntVar(&opts.maxNotes, "max-segment-notes", 0, "CSV output: largest number of notes in a segment (default: no limit)")
+	flag.StringVar(&opts.protocol, "protocol", "", "CSV output and -optimize: JSON file with the layout of the blocks (default: the current client's layout)")
+	flag.BoolVar(&opts.optimize, "optimize", false, "merge rests to pack the track into as few segments as possible")
+	flag.StringVar(&opts.from, "from", "", "MML: time or marker to start the track at, like 00:45 or chorus")
+	flag.StringVar(&opts.to, "to", "", "MML: time or marker to end the track at, like 01:30 or bridge")
+	flag.StringVar(&opts.measures, "measures", "", "MML: measures of the track to convert, like 17-32")
+	flag.IntVar(&opts.beats, "beats", 4, "MML: number of quarter note beats in a measure, for -measures")
+	flag.BoolVar(&opts.stream, "stream", false, "write each segment as soon as it is generated (MML input and CSV output only)")
+	flag.Parse()
+
+	if opts.stream {
+		if err := streamCSV(flag.Args(), os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	inputs, err := readInputs(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	output, err := mainWithError(inputs, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
@@ -22,19 +115,159 @@ func main() {
 	fmt.Print(output)
 }
 
-func mainWithError(reader *bufio.Reader) (string, error) {
-	input, err := reader.ReadString(byte(0))
-	if err != nil && err != io.EOF {
-		return "", err
+// input is the contents of a single track read from stdin or a file
+type input struct {
+	name     string
+	contents string
+}
+
+// readInputs reads each of the files as a separate track, or reads a single
+// track from stdin if there are no files
+func readInputs(files []string) ([]input, error) {
+	if len(files) == 0 {
+		contents, err := bufio.NewReader(os.Stdin).ReadString(byte(0))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return []input{{contents: contents}}, nil
+	}
+	var inputs []input
+	for _, f := range files {
+		contents, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		inputs = append(inputs, input{name: name, contents: string(contents)})
+	}
+	return inputs, nil
+}
+
+func mainWithError(inputs []input, opts options) (string, error) {
+	var protocol encoding.Protocol
+	if opts.protocol != "" {
+		var err error
+		if protocol, err = loadProtocol(opts.protocol); err != nil {
+			return "", err
+		}
 	}
-	segments, err := performgen.Generate(input)
+	var tracks []midi.Track
+	for _, in := range inputs {
+		track, err := compile(in.contents, opts)
+		if err != nil {
+			if len(inputs) > 1 {
+				return "", fmt.Errorf("%s: %s", in.name, err)
+			}
+			return "", err
+		}
+		track.Name = in.name
+		if opts.optimize {
+			track.Sequence = encoding.Optimize(track.Sequence, protocol)
+		}
+		tracks = append(tracks, track)
+	}
+	if opts.output == "midi" {
+		buf := new(bytes.Buffer)
+		if err := midi.Write(buf, tracks); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	if len(tracks) != 1 {
+		return "", errors.New("multiple tracks can only be written with -output midi")
+	}
+	seq := tracks[0].Sequence
+	switch opts.output {
+	case "csv":
+		if opts.maxLength < 0 || opts.maxNotes < 0 {
+			return "", errors.New("-max-segment-length and -max-segment-notes must not be negative")
+		}
+		segmenter := &encoding.Segmenter{Protocol: protocol, MaxLength: opts.maxLength, MaxNotes: opts.maxNotes}
+		return segmentsCSV(seq.SegmentsWith(segmenter))
+	case "text":
+		return textnote.Export(seq, opts.interval) + "\n", nil
+	case "markers":
+		return markersCSV(tracks[0].Markers), nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s", opts.output)
+	}
+}
+
+// streamCSV reads MML from a single file, or from stdin if there are no
+// files, and writes each segment to w as soon as it has been generated
+func streamCSV(files []string, w io.Writer, opts options) error {
+	if opts.format != "mml" || opts.output != "csv" {
+		return errors.New("-stream only supports -format mml and -output csv")
+	}
+	if opts.instrument != "" || opts.concertPitch || opts.fold || opts.protocol != "" || opts.minSpacing != 0 || opts.optimize ||
+		opts.maxLength != 0 || opts.maxNotes != 0 || opts.from != "" || opts.to != "" || opts.measures != "" {
+		return errors.New("-stream only supports the -dialect flag")
+	}
+	if len(files) > 1 {
+		return errors.New("-stream only supports a single input")
+	}
+	dialect, err := mml.LookupDialect(opts.dialect)
 	if err != nil {
-		return "", err
+		return err
+	}
+	r := io.Reader(os.Stdin)
+	if len(files) == 1 {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if _, err := io.WriteString(w, "data,duration(ms)\n"); err != nil {
+		return err
+	}
+	stream := performgen.NewStream(r, dialect)
+	for {
+		segment, err := stream.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ms := int64(segment.Length / time.Millisecond)
+		if _, err := fmt.Fprintf(w, "%s,%d\n", segment.Block, ms); err != nil {
+			return err
+		}
+	}
+}
+
+// loadProtocol reads the layout of the blocks of a protocol from a JSON file
+func loadProtocol(file string) (encoding.Protocol, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	layout := new(encoding.Layout)
+	if err := json.Unmarshal(contents, layout); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
 	}
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return layout, nil
+}
+
+// markersCSV writes the name of each marker and its time in milliseconds
+func markersCSV(markers []mml.Marker) string {
+	buf := bytes.NewBufferString("marker,time(ms)\n")
+	for _, m := range markers {
+		fmt.Fprintf(buf, "%s,%d\n", m.Name, m.At/time.Millisecond)
+	}
+	return buf.String()
+}
+
+func segmentsCSV(segments []encoding.PerformSegment) (string, error) {
 	output := bytes.NewBufferString("data,duration(ms)\n")
 	writer := bufio.NewWriter(output)
 	for _, segment := range segments {
-		_, _ = writer.WriteString(segment.Block.String())
+		_, _ = writer.WriteString(segment.Data().String())
 		_ = writer.WriteByte(',')
 		ms := int64(segment.Length / time.Millisecond)
 		_, _ = writer.WriteString(strconv.FormatInt(ms, 10))
@@ -45,3 +278,69 @@ func mainWithError(reader *bufio.Reader) (string, error) {
 	}
 	return output.String(), nil
 }
+
+func compile(input string, opts options) (midi.Track, error) {
+	sec, partial, err := section(opts)
+	if err != nil {
+		return midi.Track{}, err
+	}
+	if partial && opts.format != "mml" {
+		return midi.Track{}, errors.New("-from, -to, and -measures only support -format mml")
+	}
+	switch opts.format {
+	case "mml":
+		dialect, err := mml.LookupDialect(opts.dialect)
+		if err != nil {
+			return midi.Track{}, err
+		}
+		state := &mml.State{Dialect: dialect, ConcertPitch: opts.concertPitch, FoldOctaves: opts.fold, MinSpacing: opts.minSpacing}
+		if opts.instrument != "" {
+			if state.Instrument, err = mml.LookupInstrument(opts.instrument); err != nil {
+				return midi.Track{}, err
+			}
+		}
+		if partial {
+			err = performgen.RunSection(input, state, sec)
+		} else {
+			err = performgen.RunState(input, state)
+		}
+		if err != nil {
+			return midi.Track{}, err
+		}
+		for _, w := range state.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		return midi.FromState("", state), nil
+	case "musicxml":
+		score, err := musicxml.Parse(strings.NewReader(input))
+		if err != nil {
+			return midi.Track{}, err
+		}
+		seq, tempos, err := musicxml.ConvertWithTempos(score, musicxml.Options{Part: opts.part, Voice: opts.voice})
+		return midi.Track{Sequence: seq, Tempos: midi.Tempos(tempos)}, err
+	case "abc":
+		tunes, err := abc.Parse(strings.NewReader(input))
+		if err != nil {
+			return midi.Track{}, err
+		}
+		if len(tunes) == 0 {
+			return midi.Track{}, errors.New("no tunes found")
+		}
+		tune := tunes[0]
+		if opts.tune != 0 {
+			if tune, err = abc.Find(tunes, opts.tune); err != nil {
+				return midi.Track{}, err
+			}
+		}
+		seq, tempos, err := abc.ConvertWithTempos(tune)
+		return midi.Track{Sequence: seq, Tempos: midi.Tempos(tempos)}, err
+	case "text":
+		if opts.tempo <= 0 {
+			return midi.Track{}, fmt.Errorf("invalid tempo: %d", opts.tempo)
+		}
+		seq, err := textnote.Parse(strings.NewReader(input), opts.interval)
+		return midi.Track{Sequence: seq, Tempos: []mml.TempoChange{{Tempo: opts.tempo}}}, err
+	default:
+		return midi.Track{}, fmt.Errorf("unknown input format: %s", opts.format)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+const replHelp = `Type MML to play it after everything played so far. Commands:
+  :state            show the tempo, length, octave, and key
+  :segments         show the segments of everything played so far
+  :preview [file]   write everything played so far as a WAV file (default: preview.wav)
+  :reset            forget everything played so far
+  :help             show this help
+  :quit             exit
+`
+
+// repl keeps a state alive between lines of MML
+type repl struct {
+	dialect *mml.Dialect
+	state   *mml.State
+	out     io.Writer
+}
+
+// runRepl reads lines of MML from stdin and writes what each line plays
+func runRepl(args []string, stdin io.Reader, stdout io.Writer) error {
+	var dialectName string
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.StringVar(&dialectName, "dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	_ = flags.Parse(args)
+
+	dialect, err := mml.LookupDialect(dialectName)
+	if err != nil {
+		return err
+	}
+	r := &repl{dialect: dialect, out: stdout}
+	r.reset()
+
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			r.play(line)
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case ":quit", ":q":
+			return nil
+		case ":help":
+			fmt.Fprint(stdout, replHelp)
+		case ":reset":
+			r.reset()
+		case ":state":
+			r.printState()
+		case ":segments":
+			r.printSegments(r.state.Sequence)
+		case ":preview":
+			file := "preview.wav"
+			if len(fields) > 1 {
+				file = fields[1]
+			}
+			if err := r.writePreview(file); err != nil {
+				fmt.Fprintf(stdout, "error: %s\n", err)
+			}
+		default:
+			fmt.Fprintf(stdout, "unknown command %s, type :help for the list of commands\n", fields[0])
+		}
+	}
+}
+
+func (r *repl) reset() {
+	r.state = &mml.State{Dialect: r.dialect}
+}
+
+// play parses and executes a line of MML, and writes the steps and segments
+// that it played. If a command fails, the commands before it are kept.
+func (r *repl) play(line string) {
+	ast, err := mml.NewDialectParser(strings.NewReader(line), r.dialect).Parse()
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %s\n", err)
+		return
+	}
+	start := len(r.state.Sequence)
+	for i, cmd := range ast.Sequence {
+		if err = cmd.Execute(r.state); err != nil {
+			err = &mml.ExecError{Position: ast.Positions[i], Span: ast.Spans[i], Command: cmd, Err: err}
+			break
+		}
+	}
+	steps := r.state.Sequence[start:]
+	for _, step := range steps {
+		switch s := step.(type) {
+		case encoding.Note:
+			fmt.Fprintf(r.out, "%d %s\n", s, s.Label())
+		case encoding.Delay:
+			fmt.Fprintf(r.out, "wait %dms\n", s.Length()/time.Millisecond)
+		case encoding.ToneChange:
+			fmt.Fprintf(r.out, "instrument %d\n", s)
+		}
+	}
+	if len(steps) > 0 {
+		r.printSegments(steps)
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %s\n", err)
+	}
+}
+
+func (r *repl) printState() {
+	tempo, length, key := r.state.Tempo, r.state.Length, r.state.Key
+	if tempo == 0 {
+		tempo = 120
+	}
+	if length == 0 {
+		length = 4
+	}
+	if key == "" {
+		key = "none"
+	}
+	fmt.Fprintf(r.out, "tempo %d, length %d, octave %d, key %s, %dms played\n",
+		tempo, length, r.state.CurrentOctave(), key, r.state.Sequence.Length()/time.Millisecond)
+}
+
+func (r *repl) printSegments(seq encoding.Sequence) {
+	for _, segment := range seq.Segments() {
+		fmt.Fprintf(r.out, "%s,%d\n", segment.Block, segment.Length/time.Millisecond)
+	}
+}
+
+func (r *repl) writePreview(file string) error {
+	if err := writePreviewFile(file, r.state.Sequence); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "wrote %s\n", file)
+	return nil
+}
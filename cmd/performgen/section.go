@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen"
+)
+
+// section returns the section of the track selected with -from and -to or
+// -measures, and false if the whole track should be converted
+func section(opts options) (performgen.Section, bool, error) {
+	s := performgen.Section{BeatsPerMeasure: opts.beats}
+	if opts.beats < 1 {
+		return s, false, errors.New("-beats must be at least 1")
+	}
+	if opts.measures != "" {
+		if opts.from != "" || opts.to != "" {
+			return s, false, errors.New("-measures can't be used with -from or -to")
+		}
+		parts := strings.SplitN(opts.measures, "-", 2)
+		first, err := strconv.Atoi(parts[0])
+		last := first
+		if err == nil && len(parts) == 2 {
+			last, err = strconv.Atoi(parts[1])
+		}
+		if err != nil || first < 1 || last < first {
+			return s, false, fmt.Errorf("invalid measures: %s (expected a measure or a range like 17-32)", opts.measures)
+		}
+		s.From.Measure = first
+		s.To.Measure = last + 1
+		return s, true, nil
+	}
+	if opts.from == "" && opts.to == "" {
+		return s, false, nil
+	}
+	var err error
+	if opts.from != "" {
+		if s.From, err = parsePoint(opts.from); err != nil {
+			return s, false, err
+		}
+	}
+	if opts.to != "" {
+		if s.To, err = parsePoint(opts.to); err != nil {
+			return s, false, err
+		}
+	}
+	return s, true, nil
+}
+
+// parsePoint parses the name of a marker or a time in a song. Times with a
+// colon can't be marker names, but a name like 90s is only a time if the track
+// has no marker with that name.
+func parsePoint(s string) (performgen.Point, error) {
+	t, err := parseSongTime(s)
+	if strings.Contains(s, ":") {
+		return performgen.Point{Time: t}, err
+	}
+	if err != nil {
+		return performgen.Point{Marker: s}, nil
+	}
+	return performgen.Point{Marker: s, Time: t}, nil
+}
+
+// parseSongTime parses a time in a song written like 01:30, 1:02:03.5, or as
+// a duration like 90s
+func parseSongTime(s string) (time.Duration, error) {
+	if !strings.Contains(s, ":") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time: %s (expected a time like 01:30 or 90s)", s)
+		}
+		return d, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time: %s (expected a time like 01:30 or 90s)", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, fmt.Errorf("invalid time: %s (expected a time like 01:30 or 90s)", s)
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("invalid time: %s (expected a time like 01:30 or 90s)", s)
+		}
+		d += time.Duration(n) * unit
+		unit *= 60
+	}
+	return d, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+	"github.com/ff14wed/performgen/preview"
+)
+
+// trackStats are the numbers that are compared between builds of a track
+type trackStats struct {
+	notes    int
+	segments int
+	duration time.Duration
+}
+
+// watcher regenerates a track every time its file changes
+type watcher struct {
+	file    string
+	dialect *mml.Dialect
+	preview string
+	out     io.Writer
+
+	modTime  time.Time
+	size     int64
+	contents string
+	// last are the stats of the last successful build
+	last *trackStats
+}
+
+// runWatch polls an MML file and regenerates it every time it changes
+func runWatch(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		w        = &watcher{out: stdout}
+		dialect  string
+		interval time.Duration
+	)
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.StringVar(&dialect, "dialect", "performgen", "MML dialect: performgen, mabinogi, archeage, or 3mle")
+	flags.DurationVar(&interval, "interval", 500*time.Millisecond, "how often to check the file for changes")
+	flags.StringVar(&w.preview, "preview", "", "WAV file to render the track to after every change")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("watch requires a single MML file")
+	}
+	w.file = flags.Arg(0)
+	var err error
+	if w.dialect, err = mml.LookupDialect(dialect); err != nil {
+		return err
+	}
+	if _, err := w.check(); err != nil {
+		return err
+	}
+	for range time.Tick(interval) {
+		if _, err := w.check(); err != nil {
+			fmt.Fprintf(stdout, "%s: %s\n", w.file, err)
+		}
+	}
+	return nil
+}
+
+// check rebuilds the track if the file changed since the last check, and
+// returns true if it did
+func (w *watcher) check() (bool, error) {
+	info, err := os.Stat(w.file)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	contents, err := ioutil.ReadFile(w.file)
+	if err != nil {
+		return false, err
+	}
+	if w.last != nil && string(contents) == w.contents {
+		return false, nil
+	}
+	w.contents = string(contents)
+	w.build()
+	return true, nil
+}
+
+// build generates the track and writes its stats compared to the last
+// successful build, or the error if it can't be generated
+func (w *watcher) build() {
+	state, err := performgen.RunDialect(w.contents, w.dialect)
+	if err != nil {
+		fmt.Fprintf(w.out, "%s: %s\n", w.file, err)
+		return
+	}
+	stats := &trackStats{
+		segments: len(state.Sequence.Segments()),
+		duration: state.Sequence.Length(),
+	}
+	for _, step := range state.Sequence {
+		if _, ok := step.(encoding.Note); ok {
+			stats.notes++
+		}
+	}
+	fmt.Fprintf(w.out, "%s: %s\n", w.file, stats.diff(w.last))
+	w.last = stats
+
+	if w.preview != "" {
+		if err := writePreviewFile(w.preview, state.Sequence); err != nil {
+			fmt.Fprintf(w.out, "%s: %s\n", w.preview, err)
+			return
+		}
+		fmt.Fprintf(w.out, "wrote %s\n", w.preview)
+	}
+}
+
+// diff describes the stats along with how they changed since the previous
+// stats, if there are any
+func (s *trackStats) diff(prev *trackStats) string {
+	var parts []string
+	add := func(value string, change string) {
+		if change != "" {
+			value += " (" + change + ")"
+		}
+		parts = append(parts, value)
+	}
+	var notes, segments, duration string
+	if prev != nil {
+		notes = intChange(s.notes - prev.notes)
+		segments = intChange(s.segments - prev.segments)
+		if d := s.duration - prev.duration; d > 0 {
+			duration = "+" + d.String()
+		} else if d < 0 {
+			duration = d.String()
+		}
+	}
+	add(fmt.Sprintf("%d notes", s.notes), notes)
+	add(fmt.Sprintf("%d segments", s.segments), segments)
+	add(s.duration.String(), duration)
+	return strings.Join(parts, ", ")
+}
+
+func intChange(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+d", n)
+}
+
+// writePreviewFile renders the sequence to a WAV file
+func writePreviewFile(file string, seq encoding.Sequence) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := preview.WriteWAV(context.Background(), f, seq); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,215 @@
+// Package diff compares what two tracks play, note by note, instead of
+// comparing their text
+package diff
+
+import (
+	"bytes"
+	"math"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// Note is a note of a track along with when it is played
+type Note struct {
+	Note encoding.Note
+	At   time.Duration
+	// Beat is the number of quarter note beats played before the note
+	Beat float64
+}
+
+// Position returns the measure and the beat within the measure at which the
+// note is played, both counting from 1
+func (n Note) Position(beatsPerMeasure int) (measure int, beat float64) {
+	m := math.Floor(n.Beat / float64(beatsPerMeasure))
+	return int(m) + 1, n.Beat - m*float64(beatsPerMeasure) + 1
+}
+
+// Notes parses and executes the MML, and returns the notes that it plays
+func Notes(input string, d *mml.Dialect) ([]Note, error) {
+	ast, err := mml.NewDialectParser(bytes.NewReader([]byte(input)), d).Parse()
+	if err != nil {
+		return nil, err
+	}
+	state := &mml.State{Dialect: d}
+	var beats []float64
+	for i, cmd := range ast.Sequence {
+		start, beat := len(state.Sequence), state.Beats
+		if err := cmd.Execute(state); err != nil {
+			return nil, &mml.ExecError{Position: ast.Positions[i], Span: ast.Spans[i], Command: cmd, Err: err}
+		}
+		for _, step := range state.Sequence[start:] {
+			if _, ok := step.(encoding.Note); ok {
+				beats = append(beats, beat)
+			}
+		}
+	}
+	var notes []Note
+	for i, n := range state.Sequence.Timeline() {
+		notes = append(notes, Note{Note: n.Note, At: n.At, Beat: beats[i]})
+	}
+	return notes, nil
+}
+
+// Kind is the kind of a change between two tracks
+type Kind int
+
+// The kinds of changes
+const (
+	// Inserted notes are only played by the new track
+	Inserted Kind = iota
+	// Removed notes are only played by the old track
+	Removed
+	// Shifted notes are played by both tracks, but at different times
+	Shifted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Inserted:
+		return "inserted"
+	case Removed:
+		return "removed"
+	case Shifted:
+		return "shifted"
+	}
+	return "unknown"
+}
+
+// Change is a run of consecutive notes that changed in the same way. Old
+// holds the notes of the old track and New the notes of the new track, so
+// inserted notes only have New and removed notes only have Old. Shifted notes
+// have the same number of notes in both, all shifted by the same amount of
+// time.
+type Change struct {
+	Kind Kind
+	Old  []Note
+	New  []Note
+}
+
+// Shift returns how much later the new notes are played than the old notes
+func (c Change) Shift() time.Duration {
+	if len(c.Old) == 0 || len(c.New) == 0 {
+		return 0
+	}
+	return c.New[0].At - c.Old[0].At
+}
+
+// Compare aligns the notes of two tracks and returns the changes from the old
+// track to the new track, in the order of the notes in the tracks. Notes are
+// aligned by finding the longest common sequence of pitches, so a note that
+// changed pitch is both removed and inserted. Notes that are played at the
+// same time in both tracks aren't changes.
+func Compare(old, new []Note) []Change {
+	var (
+		changes []Change
+		// end is the index after the last old and new note of the last change
+		end [2]int
+	)
+	// add adds the old note at i and new note at j to the changes, where -1
+	// means that the change doesn't have a note of that track
+	add := func(kind Kind, i, j int) {
+		if kind == Shifted && old[i].At == new[j].At {
+			return
+		}
+		if last := len(changes) - 1; last >= 0 && changes[last].Kind == kind &&
+			(i < 0 || i == end[0]) && (j < 0 || j == end[1]) &&
+			(kind != Shifted || changes[last].Shift() == new[j].At-old[i].At) {
+			c := &changes[last]
+			if i >= 0 {
+				c.Old = old[end[0]-len(c.Old) : i+1]
+				end[0] = i + 1
+			}
+			if j >= 0 {
+				c.New = new[end[1]-len(c.New) : j+1]
+				end[1] = j + 1
+			}
+			return
+		}
+		c := Change{Kind: kind}
+		if i >= 0 {
+			c.Old = old[i : i+1]
+			end[0] = i + 1
+		}
+		if j >= 0 {
+			c.New = new[j : j+1]
+			end[1] = j + 1
+		}
+		changes = append(changes, c)
+	}
+
+	i, j := 0, 0
+	for _, m := range align(old, new) {
+		for ; i < m[0]; i++ {
+			add(Removed, i, -1)
+		}
+		for ; j < m[1]; j++ {
+			add(Inserted, -1, j)
+		}
+		add(Shifted, i, j)
+		i, j = i+1, j+1
+	}
+	for ; i < len(old); i++ {
+		add(Removed, i, -1)
+	}
+	for ; j < len(new); j++ {
+		add(Inserted, -1, j)
+	}
+	return changes
+}
+
+// align returns the pairs of indexes of the old and new notes in the longest
+// common sequence of pitches of both tracks
+func align(old, new []Note) [][2]int {
+	// Notes at the start and end that are the same in both tracks don't
+	// need to be compared with every other note
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix].Note == new[prefix].Note {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix].Note == new[len(new)-1-suffix].Note {
+		suffix++
+	}
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+
+	// lengths[x][y] is the length of the longest common sequence of a[x:]
+	// and b[y:]
+	lengths := make([][]int, len(a)+1)
+	for x := range lengths {
+		lengths[x] = make([]int, len(b)+1)
+	}
+	for x := len(a) - 1; x >= 0; x-- {
+		for y := len(b) - 1; y >= 0; y-- {
+			if a[x].Note == b[y].Note {
+				lengths[x][y] = lengths[x+1][y+1] + 1
+			} else if lengths[x+1][y] >= lengths[x][y+1] {
+				lengths[x][y] = lengths[x+1][y]
+			} else {
+				lengths[x][y] = lengths[x][y+1]
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for x := 0; x < prefix; x++ {
+		pairs = append(pairs, [2]int{x, x})
+	}
+	for x, y := 0, 0; x < len(a) && y < len(b); {
+		switch {
+		case a[x].Note == b[y].Note:
+			pairs = append(pairs, [2]int{prefix + x, prefix + y})
+			x, y = x+1, y+1
+		case lengths[x+1][y] >= lengths[x][y+1]:
+			x++
+		default:
+			y++
+		}
+	}
+	for s := suffix; s > 0; s-- {
+		pairs = append(pairs, [2]int{len(old) - s, len(new) - s})
+	}
+	return pairs
+}
@@ -0,0 +1,85 @@
+package diff_test
+
+import (
+	"time"
+
+	"github.com/ff14wed/performgen/diff"
+	"github.com/ff14wed/performgen/mml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	notes := func(input string) []diff.Note {
+		n, err := diff.Notes(input, mml.Performgen)
+		Expect(err).ToNot(HaveOccurred())
+		return n
+	}
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+	Describe("Notes", func() {
+		It("returns the time and beat at which each note is played", func() {
+			Expect(notes("t120 c0e0g4 r2 l8 >c")).To(Equal([]diff.Note{
+				{Note: 13, At: 0, Beat: 0},
+				{Note: 17, At: ms(20), Beat: 0},
+				{Note: 20, At: ms(40), Beat: 0},
+				{Note: 25, At: ms(1540), Beat: 3},
+			}))
+		})
+		It("returns the errors of the MML", func() {
+			_, err := diff.Notes("o9", mml.Performgen)
+			Expect(err).To(MatchError("execution error at line 1, column 1: cannot set octave to anything other than 3, 4, 5, or 6"))
+		})
+	})
+
+	Describe("Position", func() {
+		It("returns the measure and beat of the note", func() {
+			measure, beat := diff.Note{Beat: 5.5}.Position(4)
+			Expect(measure).To(Equal(2))
+			Expect(beat).To(Equal(2.5))
+			measure, beat = diff.Note{Beat: 6}.Position(3)
+			Expect(measure).To(Equal(3))
+			Expect(beat).To(Equal(1.0))
+		})
+	})
+
+	Describe("Compare", func() {
+		It("returns nothing if the tracks play the same notes at the same times", func() {
+			Expect(diff.Compare(notes("cdef"), notes("c d e f"))).To(BeEmpty())
+		})
+		It("groups consecutive notes that changed in the same way", func() {
+			old := notes("t120 l4 cdef")
+			new := notes("t120 l4 c8 d e g a")
+			changes := diff.Compare(old, new)
+			Expect(changes).To(Equal([]diff.Change{
+				{Kind: diff.Shifted, Old: old[1:3], New: new[1:3]},
+				{Kind: diff.Removed, Old: old[3:4]},
+				{Kind: diff.Inserted, New: new[3:5]},
+			}))
+			Expect(changes[0].Shift()).To(Equal(ms(-250)))
+			Expect(changes[1].Shift()).To(BeZero())
+		})
+		It("aligns the notes after an insertion", func() {
+			old := notes("cdefg")
+			new := notes("cd>c<efg")
+			Expect(diff.Compare(old, new)).To(Equal([]diff.Change{
+				{Kind: diff.Inserted, New: new[2:3]},
+				{Kind: diff.Shifted, Old: old[2:5], New: new[3:6]},
+			}))
+		})
+		It("doesn't group notes that are separated by unchanged notes", func() {
+			old := notes("cdcd")
+			new := notes("cecd")
+			Expect(diff.Compare(old, new)).To(Equal([]diff.Change{
+				{Kind: diff.Removed, Old: old[1:2]},
+				{Kind: diff.Inserted, New: new[1:2]},
+			}))
+			old = notes("cdcd")
+			new = notes("crcr")
+			Expect(diff.Compare(old, new)).To(Equal([]diff.Change{
+				{Kind: diff.Removed, Old: old[1:2]},
+				{Kind: diff.Removed, Old: old[3:4]},
+			}))
+		})
+	})
+})
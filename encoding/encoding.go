@@ -1,13 +1,16 @@
 package encoding
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Sequence is a series of steps and can possibly be encoded in a single or
 // more than one Perform block
 type Sequence []Step
 
-// Step defines a single step (note or delay) in the wire format for a sequence
-// for a Perform data block
+// Step defines a single step (note, delay, or opcode) in the wire format for a
+// sequence for a Perform data block
 type Step interface {
 	Encode() []byte
 	Length() time.Duration
@@ -22,50 +25,193 @@ func (n Note) Encode() []byte { return []byte{byte(n)} }
 // Length determines the length in time of the encoded note
 func (n Note) Length() time.Duration { return 0 }
 
+var noteNames = [12]string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "G#", "A", "Bb", "B"}
+
+// Label returns the in-game label of the note, such as "Bb (-1)" or
+// "C (+2)". Note IDs outside of the 37 playable keys have no label.
+func (n Note) Label() string {
+	if n < 1 || n > 37 {
+		return ""
+	}
+	idx := int(n) - 1
+	return fmt.Sprintf("%s (%+d)", noteNames[idx%12], idx/12-1)
+}
+
 // Delay is a step that encodes the number of milliseconds of delay (1 - 250)
 type Delay byte
 
 // Encode encodes a delay to its wire format (0xFF DELAY)
-func (r Delay) Encode() []byte { return []byte{0xFF, byte(r)} }
+func (r Delay) Encode() []byte { return []byte{DelayOpcode, byte(r)} }
 
 // Length determines the length in time of the encoded delay
 func (r Delay) Length() time.Duration { return time.Duration(r) * time.Millisecond }
 
+// ToneChange is a step that switches the performer to the instrument with the
+// given number in game
+type ToneChange byte
+
+// Encode encodes a tone change to its wire format (0xFE INSTRUMENT)
+func (t ToneChange) Encode() []byte { return []byte{ToneChangeOpcode, byte(t)} }
+
+// Length determines the length in time of the encoded tone change
+func (t ToneChange) Length() time.Duration { return 0 }
+
+// MaxDelay is the largest number of milliseconds that a single Delay step
+// should encode
+const MaxDelay = 250
+
+// Delays returns the sequence of Delay steps required to wait for the given
+// number of milliseconds, split into chunks of at most MaxDelay milliseconds
+func Delays(ms int) Sequence {
+	return delayChunks(ms, MaxDelay)
+}
+
+// delayChunks splits a delay into chunks of at most limit milliseconds
+func delayChunks(ms int, limit int) Sequence {
+	s := Sequence{}
+	for ms > 0 {
+		if ms >= limit {
+			s = append(s, Delay(limit))
+			ms -= limit
+		} else {
+			s = append(s, Delay(byte(ms)))
+			ms = 0
+		}
+	}
+	return s
+}
+
 // PerformSegment encapsulates a single block of a performance. It's not a
 // measure. It only encapsulates what can fit in a single packet of data.
 type PerformSegment struct {
-	Block  *Perform
-	Length time.Duration
+	Block *Perform
+	// Encoded is the block of a protocol whose blocks aren't a Perform, in
+	// which case Block is nil
+	Encoded Block
+	Length  time.Duration
+}
+
+// Data returns the block of the segment in the wire format of the protocol it
+// was packed with
+func (s PerformSegment) Data() Block {
+	if s.Encoded != nil {
+		return s.Encoded
+	}
+	return s.Block
 }
 
 // Segments returns the sequence of segments containing blocks that conform
 // with the FFXIV RPC for performing a sequence of notes.
 func (s Sequence) Segments() []PerformSegment {
-	blocks := []PerformSegment{}
+	return s.SegmentsWith(new(Segmenter))
+}
 
-	buf := make([]byte, 0)
-	length := time.Duration(0)
+// SegmentsWith is like Segments, but packs the steps with the given segmenter,
+// which can be configured with a protocol and limits on each segment
+func (s Sequence) SegmentsWith(segmenter *Segmenter) []PerformSegment {
+	blocks := []PerformSegment{}
 	for _, step := range s {
-		stepBytes := step.Encode()
-		if len(buf)+len(stepBytes) > 30 {
-			blocks = append(blocks, PerformSegment{
-				Block:  createBlock(buf),
-				Length: length,
-			})
-			buf = make([]byte, 0)
-			length = 0
+		blocks = append(blocks, segmenter.Add(step)...)
+	}
+	if segment, ok := segmenter.Flush(); ok {
+		blocks = append(blocks, segment)
+	}
+	return blocks
+}
+
+// Segmenter packs steps into segments one step at a time, so that a segment
+// can be used as soon as its block is full
+type Segmenter struct {
+	// Protocol is the wire format of the blocks. If it is nil, the
+	// DefaultProtocol is used.
+	Protocol Protocol
+	// MaxLength is the longest duration of a segment, so that a player can
+	// pace the segments it sends more evenly. Delays that are longer than
+	// MaxLength are split. If it is zero, segments are only limited by the
+	// size of a block.
+	MaxLength time.Duration
+	// MaxNotes is the largest number of notes in a segment. If it is zero,
+	// segments are only limited by the size of a block.
+	MaxNotes int
+
+	buf    []byte
+	length time.Duration
+	notes  int
+}
+
+// Add adds a step to the current block, and returns the segments whose blocks
+// are full. If the step doesn't fit in the current block, or the block would
+// be longer than MaxLength or have more than MaxNotes notes, the full block is
+// returned as a segment and the step is added to a new block. Delays longer
+// than the MaxDelay of the protocol or than MaxLength are added as several
+// delays, which may end up in different blocks.
+func (s *Segmenter) Add(step Step) []PerformSegment {
+	var (
+		segments []PerformSegment
+		p        = s.protocol()
+	)
+	if d, ok := step.(Delay); ok {
+		if limit := s.delayLimit(p); int(d) > limit {
+			for _, chunk := range delayChunks(int(d), limit) {
+				segments = append(segments, s.Add(chunk)...)
+			}
+			return segments
+		}
+	}
+	stepBytes := p.EncodeStep(step)
+	_, isNote := step.(Note)
+	if len(s.buf)+len(stepBytes) > p.DataSize() ||
+		(s.MaxLength > 0 && s.length+step.Length() > s.MaxLength) ||
+		(s.MaxNotes > 0 && isNote && s.notes >= s.MaxNotes) {
+		if segment, ok := s.Flush(); ok {
+			segments = append(segments, segment)
+		}
+	}
+	s.buf = append(s.buf, stepBytes...)
+	s.length = s.length + step.Length()
+	if isNote {
+		s.notes++
+	}
+	return segments
+}
+
+// delayLimit returns the number of milliseconds of the longest delay step that
+// the protocol can encode and that fits in a segment of MaxLength
+func (s *Segmenter) delayLimit(p Protocol) int {
+	limit := p.MaxDelay()
+	if ms := int(s.MaxLength / time.Millisecond); s.MaxLength > 0 && ms < limit {
+		limit = ms
+		if limit < 1 {
+			limit = 1
 		}
-		buf = append(buf, stepBytes...)
-		length = length + step.Length()
 	}
-	if len(buf) > 0 {
-		blocks = append(blocks, PerformSegment{
-			Block:  createBlock(buf),
-			Length: length,
-		})
+	return limit
+}
+
+func (s *Segmenter) protocol() Protocol {
+	if s.Protocol == nil {
+		return DefaultProtocol
 	}
+	return s.Protocol
+}
 
-	return blocks
+// Flush returns the current block as a segment if it has any steps, and
+// starts a new block
+func (s *Segmenter) Flush() (PerformSegment, bool) {
+	if len(s.buf) == 0 {
+		return PerformSegment{}, false
+	}
+	segment := PerformSegment{Length: s.length}
+	block := s.protocol().NewBlock(s.buf)
+	if perform, ok := block.(*Perform); ok {
+		segment.Block = perform
+	} else {
+		segment.Encoded = block
+	}
+	s.buf = nil
+	s.length = 0
+	s.notes = 0
+	return segment, true
 }
 
 func createBlock(buf []byte) *Perform {
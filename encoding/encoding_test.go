@@ -14,6 +14,13 @@ var _ = Describe("Encoding", func() {
 			n := encoding.Note(12)
 			Expect(n.Encode()).To(ConsistOf(byte(12)))
 		})
+		It("has the same label as the key in game", func() {
+			Expect(encoding.Note(1).Label()).To(Equal("C (-1)"))
+			Expect(encoding.Note(11).Label()).To(Equal("Bb (-1)"))
+			Expect(encoding.Note(14).Label()).To(Equal("C# (+0)"))
+			Expect(encoding.Note(37).Label()).To(Equal("C (+2)"))
+			Expect(encoding.Note(38).Label()).To(BeEmpty())
+		})
 	})
 	Describe("Delay", func() {
 		It("encodes to a two bytes", func() {
@@ -21,6 +28,24 @@ var _ = Describe("Encoding", func() {
 			Expect(d.Encode()).To(Equal([]byte{0xFF, 128}))
 		})
 	})
+	Describe("ToneChange", func() {
+		It("encodes to two bytes that don't take any time", func() {
+			t := encoding.ToneChange(5)
+			Expect(t.Encode()).To(Equal([]byte{0xFE, 5}))
+			Expect(t.Length()).To(BeZero())
+		})
+	})
+	Describe("Delays", func() {
+		It("splits long delays into chunks of at most 250 milliseconds", func() {
+			Expect(encoding.Delays(620)).To(Equal(encoding.Sequence{
+				encoding.Delay(250), encoding.Delay(250), encoding.Delay(120),
+			}))
+		})
+		It("returns no steps for a non-positive delay", func() {
+			Expect(encoding.Delays(0)).To(BeEmpty())
+			Expect(encoding.Delays(-5)).To(BeEmpty())
+		})
+	})
 	Describe("Sequence", func() {
 		It("encodes to multiple perform data blocks", func() {
 			s := encoding.Sequence{
@@ -39,23 +64,95 @@ var _ = Describe("Encoding", func() {
 			Expect(s.Segments()).To(Equal([]encoding.PerformSegment{
 				{
 					Block: &encoding.Perform{
-						Length: 30,
+						Length: 29,
 						Data: [30]byte{
 							1, 0xFF, 0x80, 2, 0xFF, 0x80, 3, 0xFF, 0x80, 4, 0xFF, 0x80, 5, 0xFF, 0x80,
-							0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
-							6, 0xFF, 0x80, 7, 0xFF, 0x80, 8,
+							0xFF, 0xFA, 0xFF, 0x05, 0xFF, 0xFA, 0xFF, 0x05, 0xFF, 0xFA, 0xFF, 0x05, 0xFF, 0xFA,
 						},
 					},
-					Length: 1916 * time.Millisecond,
+					Length: 1655 * time.Millisecond,
 				},
 				{
 					Block: &encoding.Perform{
-						Length: 8,
-						Data:   [30]byte{0xFF, 0x80, 9, 0xFF, 0x80, 10, 0xFF, 0x80},
+						Length: 17,
+						Data: [30]byte{
+							0xFF, 0x05,
+							6, 0xFF, 0x80, 7, 0xFF, 0x80, 8, 0xFF, 0x80, 9, 0xFF, 0x80, 10, 0xFF, 0x80,
+						},
 					},
-					Length: 384 * time.Millisecond,
+					Length: 645 * time.Millisecond,
 				},
 			}))
 		})
 	})
+	Describe("Segmenter", func() {
+		It("returns a segment only when a step doesn't fit in the current block", func() {
+			segmenter := new(encoding.Segmenter)
+			for i := 0; i < 10; i++ {
+				Expect(segmenter.Add(encoding.Delay(100))).To(BeEmpty())
+				Expect(segmenter.Add(encoding.Note(1))).To(BeEmpty())
+			}
+			segments := segmenter.Add(encoding.Note(2))
+			Expect(segments).To(HaveLen(1))
+			Expect(segments[0].Block.Length).To(Equal(byte(30)))
+			Expect(segments[0].Length).To(Equal(time.Second))
+
+			segment, ok := segmenter.Flush()
+			Expect(ok).To(BeTrue())
+			Expect(segment).To(Equal(encoding.PerformSegment{
+				Block: &encoding.Perform{Length: 1, Data: [30]byte{2}},
+			}))
+			_, ok = segmenter.Flush()
+			Expect(ok).To(BeFalse())
+		})
+		It("limits the length of each segment", func() {
+			s := encoding.Sequence{
+				encoding.Note(1), encoding.Delay(250), encoding.Delay(250),
+				encoding.Note(2), encoding.Delay(250), encoding.Delay(250), encoding.Delay(250),
+				encoding.Note(3), encoding.Delay(100),
+			}
+			segments := s.SegmentsWith(&encoding.Segmenter{MaxLength: 600 * time.Millisecond})
+			var lengths []time.Duration
+			for _, segment := range segments {
+				lengths = append(lengths, segment.Length)
+			}
+			Expect(lengths).To(Equal([]time.Duration{
+				500 * time.Millisecond, 500 * time.Millisecond, 350 * time.Millisecond,
+			}))
+			Expect(segments[0].Block.Sequence()).To(Equal(s[:4]))
+			Expect(segments[1].Block.Sequence()).To(Equal(s[4:6]))
+		})
+		It("splits delays longer than the maximum length", func() {
+			s := encoding.Sequence{encoding.Note(1), encoding.Delay(250), encoding.Note(2)}
+			segments := s.SegmentsWith(&encoding.Segmenter{MaxLength: 100 * time.Millisecond})
+			Expect(segments).To(HaveLen(3))
+			Expect(segments[0].Block.Sequence()).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Delay(100)}))
+			Expect(segments[1].Block.Sequence()).To(Equal(encoding.Sequence{encoding.Delay(100)}))
+			Expect(segments[2].Block.Sequence()).To(Equal(encoding.Sequence{encoding.Delay(50), encoding.Note(2)}))
+			for _, segment := range segments {
+				Expect(segment.Length).To(BeNumerically("<=", 100*time.Millisecond))
+			}
+		})
+		It("returns every segment filled by a single delay", func() {
+			segmenter := &encoding.Segmenter{MaxLength: 40 * time.Millisecond}
+			Expect(segmenter.Add(encoding.Note(1))).To(BeEmpty())
+			segments := segmenter.Add(encoding.Delay(130))
+			Expect(segments).To(HaveLen(3))
+			Expect(segments[0].Block.Sequence()).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Delay(40)}))
+			segment, ok := segmenter.Flush()
+			Expect(ok).To(BeTrue())
+			Expect(segment.Length).To(Equal(10 * time.Millisecond))
+		})
+		It("limits the number of notes in each segment", func() {
+			s := encoding.Sequence{
+				encoding.Note(1), encoding.Delay(20), encoding.Note(2), encoding.Delay(20),
+				encoding.Note(3), encoding.Delay(20),
+			}
+			segments := s.SegmentsWith(&encoding.Segmenter{MaxNotes: 2})
+			Expect(segments).To(HaveLen(2))
+			Expect(segments[0].Block.Sequence()).To(Equal(s[:4]))
+			Expect(segments[1].Block.Sequence()).To(Equal(s[4:]))
+			Expect(segments[0].Length + segments[1].Length).To(Equal(s.Length()))
+		})
+	})
 })
@@ -0,0 +1,33 @@
+package encoding
+
+// These constants are the opcode bytes of the steps other than notes
+const (
+	// DelayOpcode starts a Delay step
+	DelayOpcode = 0xFF
+	// ToneChangeOpcode starts a ToneChange step. Note IDs only go up to 37,
+	// so the byte can't be confused with a note.
+	ToneChangeOpcode = 0xFE
+)
+
+// Opcode describes how to decode a step that is encoded as an opcode byte
+// followed by a fixed number of operand bytes
+type Opcode struct {
+	// Operands is the number of bytes after the opcode byte
+	Operands int
+	// Decode returns the step encoded by the operands
+	Decode func(operands []byte) Step
+}
+
+// Opcodes are the opcodes decoded by Perform.Sequence, by their opcode byte.
+// Every other byte is decoded as a Note. New step types only need to be added
+// here to be decoded, since Segments packs any step by its encoded bytes.
+var Opcodes = map[byte]Opcode{
+	DelayOpcode: {
+		Operands: 1,
+		Decode:   func(operands []byte) Step { return Delay(operands[0]) },
+	},
+	ToneChangeOpcode: {
+		Operands: 1,
+		Decode:   func(operands []byte) Step { return ToneChange(operands[0]) },
+	},
+}
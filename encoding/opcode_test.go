@@ -0,0 +1,41 @@
+package encoding_test
+
+import (
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// volume is a step with an opcode that isn't part of the encoding package
+type volume [2]byte
+
+func (v volume) Encode() []byte        { return []byte{0xFD, v[0], v[1]} }
+func (v volume) Length() time.Duration { return 0 }
+
+var _ = Describe("Opcodes", func() {
+	BeforeEach(func() {
+		encoding.Opcodes[0xFD] = encoding.Opcode{
+			Operands: 2,
+			Decode:   func(operands []byte) encoding.Step { return volume{operands[0], operands[1]} },
+		}
+	})
+	AfterEach(func() {
+		delete(encoding.Opcodes, 0xFD)
+	})
+	It("packs and decodes steps with new opcodes", func() {
+		seq := encoding.Sequence{encoding.Note(1), volume{3, 4}, encoding.Delay(100), encoding.Note(2)}
+		segments := seq.Segments()
+		Expect(segments).To(HaveLen(1))
+		Expect(segments[0].Block.Bytes()[:8]).To(Equal([]byte{7, 1, 0xFD, 3, 4, 0xFF, 100, 2}))
+		Expect(segments[0].Length).To(Equal(100 * time.Millisecond))
+		Expect(segments[0].Block.Sequence()).To(Equal(seq))
+	})
+	It("decodes an opcode without all of its operands as a note", func() {
+		block := &encoding.Perform{Length: 3, Data: [30]byte{1, 0xFD, 3}}
+		Expect(block.Sequence()).To(Equal(encoding.Sequence{
+			encoding.Note(1), encoding.Note(0xFD), encoding.Note(3),
+		}))
+	})
+})
@@ -0,0 +1,25 @@
+package encoding
+
+// Optimize returns a sequence that plays the same steps at the same times as
+// the given sequence, but packs into as few segments of the protocol as
+// possible. Adjacent delays are merged, delays of 0 milliseconds are dropped,
+// and each run of delays is split into as few Delay steps as the MaxDelay of
+// the protocol allows. If the protocol is nil, the DefaultProtocol is used.
+func Optimize(s Sequence, p Protocol) Sequence {
+	if p == nil {
+		p = DefaultProtocol
+	}
+	limit := p.MaxDelay()
+	optimized := Sequence{}
+	ms := 0
+	for _, step := range s {
+		if d, ok := step.(Delay); ok {
+			ms += int(d)
+			continue
+		}
+		optimized = append(optimized, delayChunks(ms, limit)...)
+		ms = 0
+		optimized = append(optimized, step)
+	}
+	return append(optimized, delayChunks(ms, limit)...)
+}
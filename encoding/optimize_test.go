@@ -0,0 +1,95 @@
+package encoding_test
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// withoutDelays returns the steps of the sequence that aren't delays, in order
+func withoutDelays(s encoding.Sequence) encoding.Sequence {
+	steps := encoding.Sequence{}
+	for _, step := range s {
+		if _, ok := step.(encoding.Delay); !ok {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// randomSequence returns a sequence of up to 200 random notes, tone changes,
+// and delays of up to 255 milliseconds
+func randomSequence(r *rand.Rand) encoding.Sequence {
+	s := encoding.Sequence{}
+	for j := r.Intn(200); j > 0; j-- {
+		switch r.Intn(4) {
+		case 0:
+			s = append(s, encoding.Note(1+r.Intn(37)))
+		case 1:
+			s = append(s, encoding.ToneChange(1+r.Intn(28)))
+		default:
+			s = append(s, encoding.Delay(r.Intn(256)))
+		}
+	}
+	return s
+}
+
+var _ = Describe("Optimize", func() {
+	It("merges adjacent delays and drops delays of 0", func() {
+		s := encoding.Sequence{
+			encoding.Delay(0), encoding.Note(1), encoding.Delay(100), encoding.Delay(100), encoding.Delay(100),
+			encoding.ToneChange(2), encoding.Delay(0), encoding.Note(3), encoding.Delay(20), encoding.Delay(0),
+		}
+		Expect(encoding.Optimize(s, nil)).To(Equal(encoding.Sequence{
+			encoding.Note(1), encoding.Delay(250), encoding.Delay(50),
+			encoding.ToneChange(2), encoding.Note(3), encoding.Delay(20),
+		}))
+	})
+	It("splits delays that are longer than the MaxDelay of the protocol", func() {
+		s := encoding.Sequence{encoding.Note(1), encoding.Delay(250), encoding.Delay(250), encoding.Delay(10)}
+		Expect(encoding.Optimize(s, nil)).To(Equal(encoding.Sequence{
+			encoding.Note(1), encoding.Delay(250), encoding.Delay(250), encoding.Delay(10),
+		}))
+		layout := &encoding.Layout{DataBytes: 6, DelayLimit: 100, DelayCode: 0xF0, ToneChangeCode: 0xF1}
+		Expect(encoding.Optimize(s, layout)).To(Equal(encoding.Sequence{
+			encoding.Note(1), encoding.Delay(100), encoding.Delay(100), encoding.Delay(100),
+			encoding.Delay(100), encoding.Delay(100), encoding.Delay(10),
+		}))
+	})
+	It("packs into fewer segments", func() {
+		s := encoding.Sequence{}
+		for i := 0; i < 5; i++ {
+			s = append(s, encoding.Note(13))
+			s = append(s, encoding.Delays(250)...)
+			s = append(s, encoding.Delays(125)...)
+			s = append(s, encoding.Delays(125)...)
+		}
+		Expect(s.Segments()).To(HaveLen(2))
+		optimized := encoding.Optimize(s, nil)
+		Expect(optimized.Segments()).To(HaveLen(1))
+		Expect(optimized.Length()).To(Equal(2500 * time.Millisecond))
+	})
+	It("keeps the length of the sequence and the order and times of the notes", func() {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			s := randomSequence(r)
+			optimized := encoding.Optimize(s, nil)
+			Expect(optimized.Length()).To(Equal(s.Length()))
+			Expect(optimized.Timeline()).To(Equal(s.Timeline()))
+			Expect(withoutDelays(optimized)).To(Equal(withoutDelays(s)))
+		}
+	})
+	It("never uses delays longer than MaxDelay with the default protocol", func() {
+		r := rand.New(rand.NewSource(2))
+		for i := 0; i < 100; i++ {
+			for _, step := range encoding.Optimize(randomSequence(r), nil) {
+				if d, ok := step.(encoding.Delay); ok {
+					Expect(int(d)).To(BeNumerically("<=", encoding.MaxDelay))
+				}
+			}
+		}
+	})
+})
@@ -2,13 +2,32 @@ package encoding
 
 import "encoding/hex"
 
-// Perform defines the struct for a perform block
+// Perform defines the struct for a perform block of the DefaultProtocol. U1
+// isn't used by the client as far as we know, and is always 0.
 type Perform struct {
 	Length byte
 	Data   [30]byte
 	U1     byte
 }
 
+// Bytes returns the 32 bytes that comprise the Perform block
+func (p *Perform) Bytes() []byte {
+	buf := make([]byte, 0, 32)
+	buf = append(buf, p.Length)
+	buf = append(buf, p.Data[:]...)
+	return append(buf, p.U1)
+}
+
+// Sequence decodes the data of the Perform block into the steps it encodes,
+// using Opcodes to decode every step that isn't a note
+func (p *Perform) Sequence() Sequence {
+	n := int(p.Length)
+	if n > len(p.Data) {
+		n = len(p.Data)
+	}
+	return performLayout.decode(p.Data[:n])
+}
+
 // String returns the hexadecimal string representation of the 32 bytes that
 // comprise the Perform block.
 func (p *Perform) String() string {
@@ -8,6 +8,30 @@ import (
 )
 
 var _ = Describe("Perform", func() {
+	Describe("Bytes", func() {
+		It("serializes the perform block to 32 bytes", func() {
+			p := encoding.Perform{
+				Length: 0xa1,
+				Data:   [30]byte{1, 2, 3},
+				U1:     0xb2,
+			}
+			Expect(p.Bytes()).To(Equal([]byte{
+				0xa1, 1, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xb2,
+			}))
+		})
+	})
+	Describe("Sequence", func() {
+		It("decodes the notes, delays, and tone changes of the perform block", func() {
+			seq := encoding.Sequence{
+				encoding.Note(1), encoding.Delay(250), encoding.Note(37), encoding.ToneChange(5),
+				encoding.Note(2), encoding.Delay(20),
+			}
+			segments := seq.Segments()
+			Expect(segments).To(HaveLen(1))
+			Expect(segments[0].Block.Sequence()).To(Equal(seq))
+		})
+	})
 	Describe("String", func() {
 		It("serializes the perform block to a hex string", func() {
 			p := encoding.Perform{
@@ -0,0 +1,167 @@
+package encoding
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// Protocol describes the wire format of perform blocks for a version of the
+// game client or a private server
+type Protocol interface {
+	// DataSize is the largest number of bytes of steps that fit in a block
+	DataSize() int
+	// MaxDelay is the largest number of milliseconds of a single delay step.
+	// Longer delays are split into several delay steps before they are
+	// encoded.
+	MaxDelay() int
+	// EncodeStep returns the bytes of a step in a block. A step is never split
+	// across blocks, so the bytes must fit in DataSize.
+	EncodeStep(step Step) []byte
+	// NewBlock returns a block with the header fields for the encoded steps
+	NewBlock(data []byte) Block
+}
+
+// Block is a perform block as encoded by a protocol
+type Block interface {
+	// Bytes returns the whole block as it is sent
+	Bytes() []byte
+	// String returns the whole block as a hexadecimal string
+	String() string
+	// Sequence decodes the steps of the block
+	Sequence() Sequence
+}
+
+// DefaultProtocol is the protocol of the current game client, where blocks are
+// a Perform
+var DefaultProtocol Protocol = performProtocol{}
+
+// performLayout is the layout of a Perform block
+var performLayout = &Layout{
+	DataBytes:      30,
+	DelayLimit:     MaxDelay,
+	DelayCode:      DelayOpcode,
+	ToneChangeCode: ToneChangeOpcode,
+	TrailerBytes:   1,
+}
+
+// performProtocol is the Layout of a Perform block, but creates Perform blocks
+type performProtocol struct{}
+
+func (performProtocol) DataSize() int               { return performLayout.DataSize() }
+func (performProtocol) MaxDelay() int               { return performLayout.MaxDelay() }
+func (performProtocol) EncodeStep(step Step) []byte { return step.Encode() }
+func (performProtocol) NewBlock(data []byte) Block  { return createBlock(data) }
+
+// Layout is a Protocol for blocks that start with HeaderBytes bytes of zeros
+// and a byte with the number of bytes of steps, followed by the steps padded
+// with zeros to DataBytes, and then TrailerBytes bytes of zeros. The current
+// client has no header, 30 bytes of steps, and a single trailer byte, which is
+// U1 of a Perform block.
+type Layout struct {
+	HeaderBytes int `json:"headerBytes"`
+	DataBytes   int `json:"dataBytes"`
+	// DelayLimit is the largest number of milliseconds of a single delay step
+	DelayLimit int `json:"delayLimit"`
+	// DelayCode and ToneChangeCode are the opcode bytes of delays and tone
+	// changes
+	DelayCode      byte `json:"delayCode"`
+	ToneChangeCode byte `json:"toneChangeCode"`
+	TrailerBytes   int  `json:"trailerBytes"`
+}
+
+// Validate returns an error if blocks of the layout can't be created
+func (l *Layout) Validate() error {
+	if l.DataBytes < 2 || l.DataBytes > 255 {
+		return errors.New("invalid protocol layout: dataBytes must be between 2 and 255")
+	}
+	if l.DelayLimit < 1 || l.DelayLimit > 255 {
+		return errors.New("invalid protocol layout: delayLimit must be between 1 and 255")
+	}
+	if l.DelayCode == l.ToneChangeCode {
+		return errors.New("invalid protocol layout: delayCode and toneChangeCode must be different")
+	}
+	if l.HeaderBytes < 0 || l.TrailerBytes < 0 {
+		return errors.New("invalid protocol layout: headerBytes and trailerBytes must not be negative")
+	}
+	return nil
+}
+
+// BlockSize is the size of the header, the length byte, the steps, and the
+// trailer
+func (l *Layout) BlockSize() int { return l.HeaderBytes + 1 + l.DataBytes + l.TrailerBytes }
+
+// DataSize is the number of bytes of steps in a block
+func (l *Layout) DataSize() int { return l.DataBytes }
+
+// MaxDelay is the largest number of milliseconds of a single delay step
+func (l *Layout) MaxDelay() int { return l.DelayLimit }
+
+// EncodeStep encodes delays and tone changes with the opcodes of the layout,
+// and every other step as it encodes itself
+func (l *Layout) EncodeStep(step Step) []byte {
+	switch s := step.(type) {
+	case Delay:
+		return []byte{l.DelayCode, byte(s)}
+	case ToneChange:
+		return []byte{l.ToneChangeCode, byte(s)}
+	}
+	return step.Encode()
+}
+
+// NewBlock returns a block of the layout with the encoded steps
+func (l *Layout) NewBlock(data []byte) Block {
+	return &LayoutBlock{Layout: l, Data: append([]byte(nil), data...)}
+}
+
+// decode decodes delays and tone changes with the opcodes of the layout, other
+// opcodes with Opcodes, and every other byte as a note
+func (l *Layout) decode(data []byte) Sequence {
+	seq := Sequence{}
+	for i := 0; i < len(data); i++ {
+		if i+1 < len(data) && data[i] == l.DelayCode {
+			seq = append(seq, Delay(data[i+1]))
+			i++
+			continue
+		}
+		if i+1 < len(data) && data[i] == l.ToneChangeCode {
+			seq = append(seq, ToneChange(data[i+1]))
+			i++
+			continue
+		}
+		if data[i] != DelayOpcode && data[i] != ToneChangeOpcode {
+			if op, ok := Opcodes[data[i]]; ok && i+op.Operands < len(data) {
+				seq = append(seq, op.Decode(data[i+1:i+1+op.Operands]))
+				i += op.Operands
+				continue
+			}
+		}
+		seq = append(seq, Note(data[i]))
+	}
+	return seq
+}
+
+// LayoutBlock is a block of a Layout
+type LayoutBlock struct {
+	Layout *Layout
+	// Data is the encoded steps of the block
+	Data []byte
+}
+
+// Bytes returns the BlockSize bytes of the block
+func (b *LayoutBlock) Bytes() []byte {
+	buf := make([]byte, b.Layout.BlockSize())
+	data := buf[b.Layout.HeaderBytes:]
+	data[0] = byte(len(b.Data))
+	copy(data[1:1+b.Layout.DataBytes], b.Data)
+	return buf
+}
+
+// String returns the hexadecimal string representation of the block
+func (b *LayoutBlock) String() string {
+	return hex.EncodeToString(b.Bytes())
+}
+
+// Sequence decodes the steps of the block
+func (b *LayoutBlock) Sequence() Sequence {
+	return b.Layout.decode(b.Data)
+}
@@ -0,0 +1,81 @@
+package encoding_test
+
+import (
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Protocol", func() {
+	It("creates Perform blocks by default", func() {
+		Expect(encoding.DefaultProtocol.DataSize()).To(Equal(30))
+		Expect(encoding.DefaultProtocol.MaxDelay()).To(Equal(encoding.MaxDelay))
+		block := encoding.DefaultProtocol.NewBlock([]byte{1, 0xFF, 100})
+		Expect(block).To(Equal(&encoding.Perform{Length: 3, Data: [30]byte{1, 0xFF, 100}}))
+	})
+
+	Describe("Layout", func() {
+		var layout *encoding.Layout
+		BeforeEach(func() {
+			layout = &encoding.Layout{DataBytes: 6, DelayLimit: 100, DelayCode: 0xF0, ToneChangeCode: 0xF1, TrailerBytes: 2}
+		})
+
+		It("is valid only if blocks of the layout can be created", func() {
+			Expect(layout.Validate()).To(Succeed())
+			layout.DataBytes = 1
+			Expect(layout.Validate()).To(MatchError(ContainSubstring("dataBytes")))
+			layout.DataBytes = 6
+			layout.DelayLimit = 0
+			Expect(layout.Validate()).To(MatchError(ContainSubstring("delayLimit")))
+			layout.DelayLimit = 100
+			layout.HeaderBytes = -1
+			Expect(layout.Validate()).To(MatchError(ContainSubstring("headerBytes")))
+			layout.HeaderBytes = 0
+			layout.ToneChangeCode = 0xF0
+			Expect(layout.Validate()).To(MatchError(ContainSubstring("must be different")))
+		})
+
+		It("packs steps into blocks of the layout, splitting long delays", func() {
+			seq := encoding.Sequence{encoding.Delay(250), encoding.Note(1), encoding.ToneChange(3), encoding.Note(2)}
+			segments := seq.SegmentsWith(&encoding.Segmenter{Protocol: layout})
+			Expect(segments).To(HaveLen(2))
+			Expect(segments[0].Block).To(BeNil())
+			Expect(segments[0].Encoded.Bytes()).To(Equal([]byte{6, 0xF0, 100, 0xF0, 100, 0xF0, 50, 0, 0}))
+			Expect(segments[0].Length).To(Equal(250 * time.Millisecond))
+			Expect(segments[1].Data().String()).To(Equal("0401f1030200000000"))
+			Expect(segments[1].Length).To(BeZero())
+
+			var decoded encoding.Sequence
+			for _, s := range segments {
+				decoded = append(decoded, s.Data().Sequence()...)
+			}
+			Expect(decoded).To(Equal(encoding.Sequence{
+				encoding.Delay(100), encoding.Delay(100), encoding.Delay(50), encoding.Note(1), encoding.ToneChange(3), encoding.Note(2),
+			}))
+		})
+
+		It("puts delays that don't fit in the current block in the next one", func() {
+			seq := encoding.Sequence{encoding.Note(1), encoding.Note(2), encoding.Delay(250)}
+			segments := seq.SegmentsWith(&encoding.Segmenter{Protocol: layout})
+			Expect(segments).To(HaveLen(2))
+			Expect(segments[0].Data().Sequence()).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Note(2), encoding.Delay(100), encoding.Delay(100)}))
+			Expect(segments[0].Length).To(Equal(200 * time.Millisecond))
+			Expect(segments[1].Data().Sequence()).To(Equal(encoding.Sequence{encoding.Delay(50)}))
+		})
+
+		It("starts blocks with the header bytes", func() {
+			layout.HeaderBytes = 2
+			Expect(layout.BlockSize()).To(Equal(11))
+			block := layout.NewBlock([]byte{1, 0xF0, 100})
+			Expect(block.Bytes()).To(Equal([]byte{0, 0, 3, 1, 0xF0, 100, 0, 0, 0, 0, 0}))
+			Expect(block.Sequence()).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Delay(100)}))
+		})
+
+		It("decodes the default opcodes as notes", func() {
+			block := layout.NewBlock([]byte{0xFF, 100})
+			Expect(block.Sequence()).To(Equal(encoding.Sequence{encoding.Note(0xFF), encoding.Note(100)}))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package encoding
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ChordDelay is the number of milliseconds of delay inserted between notes
+// that are scheduled to be played at the same time, matching the arpeggiated
+// chords produced by MML notes with a length of 0
+const ChordDelay = 20
+
+// TimedNote is a note played at a point in time, relative to the start of a
+// sequence
+type TimedNote struct {
+	Note Note
+	At   time.Duration
+}
+
+// TempoChange is a change of tempo at a position in a score. The position is
+// given in beats and the tempo in beats per minute, where a beat can be any
+// note value as long as it is the same for every change.
+type TempoChange struct {
+	Beat float64
+	BPM  float64
+}
+
+// TimeAt returns the time, rounded to the millisecond, at a position in beats
+// in a score with the given tempo changes. The tempo changes must be sorted
+// by position, starting with the tempo at position 0.
+func TimeAt(tempos []TempoChange, beat float64) time.Duration {
+	ms := 0.0
+	for i, t := range tempos {
+		if t.Beat >= beat {
+			break
+		}
+		end := beat
+		if i+1 < len(tempos) && tempos[i+1].Beat < beat {
+			end = tempos[i+1].Beat
+		}
+		ms += (end - t.Beat) * 60000 / t.BPM
+	}
+	return time.Duration(math.Round(ms)) * time.Millisecond
+}
+
+// Arrange returns the sequence that plays each note at its time and ends at
+// the given time. Notes that are played at the same time are arpeggiated with
+// ChordDelay milliseconds in between each note, borrowing the time from the
+// delay before the next note so that the following notes are not shifted.
+func Arrange(notes []TimedNote, end time.Duration) Sequence {
+	sorted := make([]TimedNote, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At < sorted[j].At })
+
+	seq := Sequence{}
+	now := 0
+	for i, n := range sorted {
+		ms := int(n.At / time.Millisecond)
+		if i > 0 && ms <= now {
+			ms = now + ChordDelay
+		}
+		seq = append(seq, Delays(ms-now)...)
+		if ms > now {
+			now = ms
+		}
+		seq = append(seq, n.Note)
+	}
+	return append(seq, Delays(int(end/time.Millisecond)-now)...)
+}
+
+// Timeline returns the notes of the sequence along with the time at which
+// they are played
+func (s Sequence) Timeline() []TimedNote {
+	var notes []TimedNote
+	at := time.Duration(0)
+	for _, step := range s {
+		if n, ok := step.(Note); ok {
+			notes = append(notes, TimedNote{Note: n, At: at})
+		}
+		at += step.Length()
+	}
+	return notes
+}
+
+// Length returns the total length in time of the sequence
+func (s Sequence) Length() time.Duration {
+	length := time.Duration(0)
+	for _, step := range s {
+		length += step.Length()
+	}
+	return length
+}
+
+// Between returns the part of the sequence that is played from the time from
+// up to the time to. Delays that start before from or end after to are
+// shortened, and notes played at to are left out. The last tone change before
+// from is kept at the start, so that the part is played on the same
+// instrument.
+func (s Sequence) Between(from, to time.Duration) Sequence {
+	part := Sequence{}
+	var (
+		tone Step
+		at   time.Duration
+	)
+	for _, step := range s {
+		d, ok := step.(Delay)
+		if !ok {
+			_, isTone := step.(ToneChange)
+			if isTone && at < from {
+				tone = step
+			}
+			if at >= from && at < to {
+				part = append(part, step)
+				if isTone && at == from {
+					tone = nil
+				}
+			}
+			continue
+		}
+		start, end := at, at+d.Length()
+		at = end
+		if start < from {
+			start = from
+		}
+		if end > to {
+			end = to
+		}
+		if end > start {
+			part = append(part, Delays(int((end-start)/time.Millisecond))...)
+		}
+	}
+	if tone != nil {
+		part = append(Sequence{tone}, part...)
+	}
+	return part
+}
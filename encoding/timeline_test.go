@@ -0,0 +1,86 @@
+package encoding_test
+
+import (
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeline", func() {
+	Describe("TimeAt", func() {
+		tempos := []encoding.TempoChange{{Beat: 0, BPM: 120}, {Beat: 4, BPM: 60}}
+		It("returns the time at a position before any tempo change", func() {
+			Expect(encoding.TimeAt(tempos, 3)).To(Equal(1500 * time.Millisecond))
+		})
+		It("adds up the time spent at each tempo", func() {
+			Expect(encoding.TimeAt(tempos, 5.5)).To(Equal(3500 * time.Millisecond))
+		})
+		It("rounds the time to the millisecond", func() {
+			Expect(encoding.TimeAt([]encoding.TempoChange{{Beat: 0, BPM: 90}}, 1)).To(Equal(667 * time.Millisecond))
+		})
+	})
+	Describe("Arrange", func() {
+		It("plays the notes at their times and ends at the given time", func() {
+			seq := encoding.Arrange([]encoding.TimedNote{
+				{Note: 3, At: 300 * time.Millisecond},
+				{Note: 1, At: 0},
+			}, 400*time.Millisecond)
+			Expect(seq).To(Equal(encoding.Sequence{
+				encoding.Note(1), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(3), encoding.Delay(100),
+			}))
+		})
+		It("arpeggiates notes played at the same time without shifting later notes", func() {
+			seq := encoding.Arrange([]encoding.TimedNote{
+				{Note: 1, At: 0},
+				{Note: 5, At: 0},
+				{Note: 8, At: 0},
+				{Note: 13, At: 100 * time.Millisecond},
+			}, 100*time.Millisecond)
+			Expect(seq).To(Equal(encoding.Sequence{
+				encoding.Note(1), encoding.Delay(20),
+				encoding.Note(5), encoding.Delay(20),
+				encoding.Note(8), encoding.Delay(60),
+				encoding.Note(13),
+			}))
+		})
+	})
+	Describe("Timeline", func() {
+		It("returns the time at which each note is played", func() {
+			seq := encoding.Sequence{
+				encoding.Delay(10), encoding.Note(1), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(3), encoding.Note(4), encoding.Delay(100),
+			}
+			Expect(seq.Timeline()).To(Equal([]encoding.TimedNote{
+				{Note: 1, At: 10 * time.Millisecond},
+				{Note: 3, At: 310 * time.Millisecond},
+				{Note: 4, At: 310 * time.Millisecond},
+			}))
+			Expect(seq.Length()).To(Equal(410 * time.Millisecond))
+		})
+	})
+	Describe("Between", func() {
+		seq := encoding.Sequence{
+			encoding.ToneChange(5), encoding.Note(1), encoding.Delay(250), encoding.Delay(250),
+			encoding.ToneChange(6), encoding.Note(2), encoding.Delay(100),
+			encoding.Note(3), encoding.Delay(200),
+		}
+		It("returns the steps played between the times", func() {
+			Expect(seq.Between(400*time.Millisecond, 700*time.Millisecond)).To(Equal(encoding.Sequence{
+				encoding.ToneChange(5), encoding.Delay(100),
+				encoding.ToneChange(6), encoding.Note(2), encoding.Delay(100),
+				encoding.Note(3), encoding.Delay(100),
+			}))
+		})
+		It("includes notes played at the start but not at the end", func() {
+			Expect(seq.Between(500*time.Millisecond, 600*time.Millisecond)).To(Equal(encoding.Sequence{
+				encoding.ToneChange(6), encoding.Note(2), encoding.Delay(100),
+			}))
+		})
+		It("returns the whole sequence from its start to its end", func() {
+			Expect(seq.Between(0, seq.Length())).To(Equal(seq))
+		})
+	})
+})
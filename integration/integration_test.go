@@ -2,7 +2,10 @@ package integration_test
 
 import (
 	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -21,9 +24,13 @@ var _ = Describe("Performgen Integration", func() {
 		stdout *gbytes.Buffer
 		stderr *gbytes.Buffer
 		cmd    *exec.Cmd
+		args   []string
 	)
 	BeforeEach(func() {
-		cmd = exec.Command(binaryPath)
+		args = nil
+	})
+	JustBeforeEach(func() {
+		cmd = exec.Command(binaryPath, args...)
 		var err error
 		stdin, err = cmd.StdinPipe()
 		Expect(err).ToNot(HaveOccurred())
@@ -59,4 +66,393 @@ var _ = Describe("Performgen Integration", func() {
 		Expect(string(stderr.Contents())).To(ContainSubstring("execution error"))
 		close(done)
 	}, 1.5)
+	Context("when the input format is musicxml", func() {
+		BeforeEach(func() {
+			args = []string{"-format", "musicxml", "-part", "Harp"}
+		})
+		It("converts the selected part to perform blocks", func(done Done) {
+			_, err := stdin.Write([]byte(`<score-partwise>
+  <part-list><score-part id="P1"><part-name>Harp</part-name></score-part></part-list>
+  <part id="P1"><measure number="1">
+    <attributes><divisions>1</divisions></attributes>
+    <note><pitch><step>A</step><octave>3</octave></pitch><duration>2</duration></note>
+  </measure></part>
+</score-partwise>`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal(`data,duration(ms)
+090afffafffafffafffa00000000000000000000000000000000000000000000,1000
+`))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when the output format is text", func() {
+		BeforeEach(func() {
+			args = []string{"-output", "text", "-interval", "500ms"}
+		})
+		It("writes the notes as in-game labels", func(done Done) {
+			_, err := stdin.Write([]byte("o3a4b-2>c+"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("A (-1), Bb (-1) 1000ms, C# (+0)\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when streaming", func() {
+		BeforeEach(func() {
+			args = []string{"-stream"}
+		})
+		It("writes segments before the input is closed", func(done Done) {
+			_, err := stdin.Write([]byte("t80o3a2b2c2d2e2f2g2 "))
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(stdout).Should(gbytes.Say("data,duration\\(ms\\)\n"))
+			Eventually(stdout).Should(gbytes.Say("1d0afffafffafffafffafffafffa0cfffafffafffafffafffafffa01fffa0000,3250\n"))
+			// The last block isn't full until the end of the input
+			Consistently(stdout, 0.1).ShouldNot(gbytes.Say("02fffa0000"))
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal(goodOutput))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 2)
+	})
+	Context("when a dialect is selected", func() {
+		BeforeEach(func() {
+			args = []string{"-dialect", "archeage", "-output", "text"}
+		})
+		It("parses the MML according to the dialect", func(done Done) {
+			_, err := stdin.Write([]byte("c4^8&c8 c4&d4"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("C (+0) 1000ms, C (+0) 500ms, D (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when an instrument is selected", func() {
+		BeforeEach(func() {
+			args = []string{"-instrument", "tuba", "-concert-pitch", "-output", "text"}
+		})
+		It("shifts the notes for the instrument", func(done Done) {
+			_, err := stdin.Write([]byte("o2c"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("C (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when a minimum spacing is set", func() {
+		BeforeEach(func() {
+			args = []string{"-min-spacing", "50ms", "-output", "text"}
+		})
+		It("spaces the notes and keeps the rest in time", func(done Done) {
+			_, err := stdin.Write([]byte("o3c0d0r8e"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("C (-1) 50ms, D (-1) 240ms, E (-1) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+		It("warns if the notes are too dense", func(done Done) {
+			_, err := stdin.Write([]byte("o3c0d0e0r4f"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("C (-1) 50ms, D (-1) 50ms, E (-1) 460ms, F (-1) 500ms\n"))
+			Expect(string(stderr.Contents())).To(Equal("warning: the note at beat 0 is played 60ms late to keep notes 50ms apart\n"))
+			close(done)
+		}, 1.5)
+	})
+	Context("when optimizing", func() {
+		BeforeEach(func() {
+			args = []string{"-optimize"}
+		})
+		It("merges the rests", func(done Done) {
+			_, err := stdin.Write([]byte("o3c16r16r16r16"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("data,duration(ms)\n" +
+				"0501fffafffa0000000000000000000000000000000000000000000000000000,500\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when the length of a segment is limited", func() {
+		BeforeEach(func() {
+			args = []string{"-max-segment-length", "1s"}
+		})
+		It("splits the track into shorter segments", func(done Done) {
+			_, err := stdin.Write([]byte("o3c1c"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("data,duration(ms)\n" +
+				"0901fffafffafffafffa00000000000000000000000000000000000000000000,1000\n" +
+				"09fffafffafffafffa0100000000000000000000000000000000000000000000,1000\n" +
+				"04fffafffa000000000000000000000000000000000000000000000000000000,500\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when a section is selected", func() {
+		BeforeEach(func() {
+			args = []string{"-from", "00:01.5", "-to", "00:03", "-output", "text"}
+		})
+		It("converts only the notes of the section", func(done Done) {
+			_, err := stdin.Write([]byte("t120 l4 o4 cdef gab>c"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("F (+0) 500ms, G (+0) 500ms, A (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when measures are selected", func() {
+		BeforeEach(func() {
+			args = []string{"-measures", "2-3", "-beats", "2", "-output", "text"}
+		})
+		It("converts only the notes of the measures", func(done Done) {
+			_, err := stdin.Write([]byte("t120 l4 o4 cdef gab>c"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("E (+0) 500ms, F (+0) 500ms, G (+0) 500ms, A (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when the output format is markers", func() {
+		BeforeEach(func() {
+			args = []string{"-output", "markers"}
+		})
+		It("writes the time of each marker", func(done Done) {
+			_, err := stdin.Write([]byte("t120 l4 *intro cd *chorus ef"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("marker,time(ms)\nintro,0\nchorus,1000\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when a section starts at a marker", func() {
+		BeforeEach(func() {
+			args = []string{"-from", "chorus", "-output", "text"}
+		})
+		It("converts the notes from the marker", func(done Done) {
+			_, err := stdin.Write([]byte("t120 l4 o4 *intro cd *chorus ef"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("E (+0) 500ms, F (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when a section starts at a marker that starts with a digit", func() {
+		BeforeEach(func() {
+			args = []string{"-from", "2nd", "-to", "2s", "-output", "text"}
+		})
+		It("converts the notes from the marker to the time", func(done Done) {
+			_, err := stdin.Write([]byte("t120 l4 o4 cd *2nd efg"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("E (+0) 500ms, F (+0) 500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when a protocol layout is given", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "performgen")
+			Expect(err).ToNot(HaveOccurred())
+			layout := filepath.Join(dir, "layout.json")
+			Expect(ioutil.WriteFile(layout, []byte(`{"dataBytes": 4, "delayLimit": 250, "delayCode": 240, "toneChangeCode": 241}`), 0644)).To(Succeed())
+			args = []string{"-protocol", layout}
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+		It("packs the steps into blocks of the layout", func(done Done) {
+			_, err := stdin.Write([]byte("t120 o3c d"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("data,duration(ms)\n" +
+				"0301f0fa00,250\n" +
+				"03f0fa0300,250\n" +
+				"04f0faf0fa,500\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when formatting MML", func() {
+		BeforeEach(func() {
+			args = []string{"fmt", "-bars"}
+		})
+		It("writes the MML with a line for each measure", func(done Done) {
+			_, err := stdin.Write([]byte("T120 L8 O4 CDEFGAB>C<BAG"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("/* bar 1 */ t120 l8 o4 cdefgab>c\n/* bar 2 */ <bag\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+		It("keeps the comments", func(done Done) {
+			_, err := stdin.Write([]byte("/* bar 3 */ /* melody */ T120 L8 O4 CDEFGAB>C /* high */\n<BAG\n/* end */"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal(
+				"/* bar 1 */ /* melody */ t120 l8 o4 cdefgab>c /* high */\n/* bar 2 */ <bag /* end */\n",
+			))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when running the REPL", func() {
+		BeforeEach(func() {
+			args = []string{"repl"}
+		})
+		It("keeps the state between lines", func(done Done) {
+			_, err := stdin.Write([]byte("o5 l16 cd\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(stdout).Should(gbytes.Say(`> 25 C \(\+1\)
+wait 125ms
+27 D \(\+1\)
+wait 125ms
+0619ff7d1bff7d00000000000000000000000000000000000000000000000000,250
+`))
+			_, err = stdin.Write([]byte(":state\no9\n:reset\n:state\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(HaveSuffix("> tempo 120, length 16, octave 5, key none, 250ms played\n" +
+				"> error: execution error at line 1, column 1: cannot set octave to anything other than 3, 4, 5, or 6\n" +
+				"> > tempo 120, length 4, octave 4, key none, 0ms played\n" +
+				"> \n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when watching a file", func() {
+		var (
+			dir  string
+			song string
+		)
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "performgen")
+			Expect(err).ToNot(HaveOccurred())
+			song = filepath.Join(dir, "song.mml")
+			Expect(ioutil.WriteFile(song, []byte("t120 c d"), 0644)).To(Succeed())
+			args = []string{"watch", "-interval", "20ms", "-preview", filepath.Join(dir, "song.wav"), song}
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+		It("regenerates the track every time the file changes", func(done Done) {
+			Eventually(stdout).Should(gbytes.Say(`song.mml: 2 notes, 1 segments, 1s\n`))
+			Eventually(stdout).Should(gbytes.Say(`wrote .*song.wav\n`))
+			Expect(filepath.Join(dir, "song.wav")).To(BeAnExistingFile())
+
+			Expect(ioutil.WriteFile(song, []byte("t120 c d e8"), 0644)).To(Succeed())
+			Eventually(stdout).Should(gbytes.Say(`song.mml: 3 notes \(\+1\), 1 segments, 1.25s \(\+250ms\)\n`))
+
+			Expect(ioutil.WriteFile(song, []byte("t120 c d o9"), 0644)).To(Succeed())
+			Eventually(stdout).Should(gbytes.Say(`song.mml: execution error at line 1, column 10: cannot set octave`))
+			Consistently(stdout, 0.1).ShouldNot(gbytes.Say("wrote"))
+
+			Expect(ioutil.WriteFile(song, []byte("t120 c"), 0644)).To(Succeed())
+			Eventually(stdout).Should(gbytes.Say(`song.mml: 1 notes \(-2\), 1 segments, 500ms \(-750ms\)\n`))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 3)
+	})
+	Context("when comparing two files", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "performgen")
+			Expect(err).ToNot(HaveOccurred())
+			old := filepath.Join(dir, "old.mml")
+			new := filepath.Join(dir, "new.mml")
+			Expect(ioutil.WriteFile(old, []byte("t120 l4 cdef gab>c"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(new, []byte("t120 l4 cdef g8b8a>c"), 0644)).To(Succeed())
+			args = []string{"diff", old, new}
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+		It("writes the notes that changed with their positions", func(done Done) {
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("measure 2, beat 2 (2500ms): removed A (+0)\n" +
+				"measure 2, beat 3 (3000ms): shifted B (+0) by -750ms\n" +
+				"measure 2, beat 2 (2500ms): inserted A (+0)\n" +
+				"measure 2, beat 4 (3500ms): shifted C (+1) by -500ms\n"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
+	Context("when several files are written as MIDI", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "performgen")
+			Expect(err).ToNot(HaveOccurred())
+			melody := filepath.Join(dir, "melody.mml")
+			harmony := filepath.Join(dir, "harmony.mml")
+			Expect(ioutil.WriteFile(melody, []byte("t60o3c"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(harmony, []byte("t60o3e"), 0644)).To(Succeed())
+			args = []string{"-output", "midi", melody, harmony}
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+		It("writes each file as a track of a type 1 MIDI file", func(done Done) {
+			Expect(stdin.Close()).To(Succeed())
+
+			Expect(cmd.Wait()).To(Succeed())
+			out := stdout.Contents()
+			Expect(out[:14]).To(Equal([]byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 2, 0x01, 0xE0}))
+			Expect(string(out)).To(ContainSubstring("melody"))
+			Expect(string(out)).To(ContainSubstring("harmony"))
+			Expect(string(stderr.Contents())).To(BeEmpty())
+			close(done)
+		}, 1.5)
+	})
 })
@@ -0,0 +1,131 @@
+package performgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// Options configures GenerateContext. A limit of 0 means there is no limit.
+type Options struct {
+	// Dialect is the dialect of the MML. If it is nil, the Performgen dialect
+	// is used.
+	Dialect *mml.Dialect
+
+	// MaxInputSize is the largest input accepted, in bytes
+	MaxInputSize int
+	// MaxNotes is the largest number of notes the track may play
+	MaxNotes int
+	// MaxDuration is the longest the track may play for
+	MaxDuration time.Duration
+	// MaxSegments is the largest number of segments that may be generated
+	MaxSegments int
+}
+
+// Limit identifies one of the limits of Options
+type Limit int
+
+// These constants define the limits that can be exceeded
+const (
+	LimitInputSize Limit = iota
+	LimitNotes
+	LimitDuration
+	LimitSegments
+)
+
+// LimitError is returned by GenerateContext when generating the track would
+// exceed one of the limits of Options
+type LimitError struct {
+	Limit Limit
+	// Max is the value of the limit that was exceeded
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitInputSize:
+		return fmt.Sprintf("input is larger than the limit of %d bytes", e.Max)
+	case LimitNotes:
+		return fmt.Sprintf("track has more than the limit of %d notes", e.Max)
+	case LimitDuration:
+		return fmt.Sprintf("track is longer than the limit of %s", time.Duration(e.Max))
+	case LimitSegments:
+		return fmt.Sprintf("track has more than the limit of %d segments", e.Max)
+	default:
+		return fmt.Sprintf("track exceeds an unknown limit of %d", e.Max)
+	}
+}
+
+// GenerateContext is like Generate, but stops as soon as the context is
+// cancelled or any of the limits of opts is exceeded. If the context is
+// cancelled, the error of the context is returned. If a limit is exceeded, a
+// *LimitError is returned.
+func GenerateContext(ctx context.Context, input string, opts Options) ([]encoding.PerformSegment, error) {
+	if opts.MaxInputSize > 0 && len(input) > opts.MaxInputSize {
+		return nil, &LimitError{Limit: LimitInputSize, Max: int64(opts.MaxInputSize)}
+	}
+	dialect := opts.Dialect
+	if dialect == nil {
+		dialect = mml.Performgen
+	}
+	parser := mml.NewDialectParser(bytes.NewReader([]byte(input)), dialect)
+	state := &mml.State{Dialect: dialect}
+
+	var (
+		segments  = []encoding.PerformSegment{}
+		segmenter encoding.Segmenter
+		notes     int
+		duration  time.Duration
+	)
+	addSegment := func(segment encoding.PerformSegment) error {
+		if opts.MaxSegments > 0 && len(segments) >= opts.MaxSegments {
+			return &LimitError{Limit: LimitSegments, Max: int64(opts.MaxSegments)}
+		}
+		segments = append(segments, segment)
+		return nil
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cmd, span, err := parser.Next()
+		if err != nil {
+			return nil, err
+		}
+		if cmd == nil {
+			break
+		}
+		if err := cmd.Execute(state); err != nil {
+			return nil, &mml.ExecError{Position: span.Start, Span: span, Command: cmd, Err: err}
+		}
+		for _, step := range state.Sequence {
+			if _, ok := step.(encoding.Note); ok {
+				notes++
+				if opts.MaxNotes > 0 && notes > opts.MaxNotes {
+					return nil, &LimitError{Limit: LimitNotes, Max: int64(opts.MaxNotes)}
+				}
+			}
+			duration += step.Length()
+			if opts.MaxDuration > 0 && duration > opts.MaxDuration {
+				return nil, &LimitError{Limit: LimitDuration, Max: int64(opts.MaxDuration)}
+			}
+			for _, segment := range segmenter.Add(step) {
+				if err := addSegment(segment); err != nil {
+					return nil, err
+				}
+			}
+		}
+		// The steps are already in the segmenter, so they don't need to be kept
+		state.Sequence = state.Sequence[:0]
+	}
+	if segment, ok := segmenter.Flush(); ok {
+		if err := addSegment(segment); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
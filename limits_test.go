@@ -0,0 +1,65 @@
+package performgen_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GenerateContext", func() {
+	var ctx context.Context
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+	It("generates the same segments as Generate without limits", func() {
+		input := "t88 b2al2b+. o5 c8d8e8f8g8a8b8>c8 <<c1"
+		expected, err := performgen.Generate(input)
+		Expect(err).ToNot(HaveOccurred())
+		segments, err := performgen.GenerateContext(ctx, input, performgen.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(segments).To(Equal(expected))
+	})
+	It("uses the dialect", func() {
+		_, err := performgen.GenerateContext(ctx, "t300", performgen.Options{Dialect: mml.Mabinogi})
+		Expect(err).To(MatchError("execution error at line 1, column 1: cannot set tempo to greater than 255"))
+	})
+	It("stops when the context is cancelled", func() {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := performgen.GenerateContext(cancelled, "cde", performgen.Options{})
+		Expect(err).To(Equal(context.Canceled))
+	})
+	DescribeTable("returns a LimitError when a limit is exceeded",
+		func(input string, opts performgen.Options, limit performgen.Limit, message string) {
+			_, err := performgen.GenerateContext(ctx, input, opts)
+			Expect(err).To(BeAssignableToTypeOf(&performgen.LimitError{}))
+			Expect(err.(*performgen.LimitError).Limit).To(Equal(limit))
+			Expect(err).To(MatchError(message))
+		},
+		Entry("input size", "cdefg", performgen.Options{MaxInputSize: 4},
+			performgen.LimitInputSize, "input is larger than the limit of 4 bytes"),
+		Entry("notes", "cdefg", performgen.Options{MaxNotes: 4},
+			performgen.LimitNotes, "track has more than the limit of 4 notes"),
+		Entry("duration", "cdefg", performgen.Options{MaxDuration: 2 * time.Second},
+			performgen.LimitDuration, "track is longer than the limit of 2s"),
+		Entry("segments", strings.Repeat("c8", 20), performgen.Options{MaxSegments: 1},
+			performgen.LimitSegments, "track has more than the limit of 1 segments"),
+	)
+	DescribeTable("succeeds when a limit is reached but not exceeded",
+		func(input string, opts performgen.Options) {
+			_, err := performgen.GenerateContext(ctx, input, opts)
+			Expect(err).ToNot(HaveOccurred())
+		},
+		Entry("input size", "cdefg", performgen.Options{MaxInputSize: 5}),
+		Entry("notes", "cdefg", performgen.Options{MaxNotes: 5}),
+		Entry("duration", "cdefg", performgen.Options{MaxDuration: 2500 * time.Millisecond}),
+		Entry("segments", strings.Repeat("c8", 10), performgen.Options{MaxSegments: 1}),
+	)
+})
@@ -0,0 +1,232 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// TicksPerQuarter is the time division of the MIDI files that are written
+const TicksPerQuarter = 480
+
+// NoteOffset is the difference between a MIDI note number and a perform note
+// ID. Perform note IDs 1 to 37 correspond to MIDI notes 48 (C3) to 84 (C6).
+const NoteOffset = 47
+
+// Velocity is the velocity of every note written, since FFXIV doesn't support
+// volume
+const Velocity = 100
+
+// Track is a single track of music to be written to a MIDI file
+type Track struct {
+	Name     string
+	Sequence encoding.Sequence
+	// Tempos are the tempo changes in the track. If a track doesn't have any
+	// tempo changes, it is assumed the tempo is 120 bpm.
+	Tempos []mml.TempoChange
+	// Markers are the rehearsal markers of the track, which are written as
+	// marker events
+	Markers []mml.Marker
+}
+
+// FromState returns a track with the sequence, tempo changes, and markers of
+// an MML state
+func FromState(name string, s *mml.State) Track {
+	return Track{Name: name, Sequence: s.Sequence, Tempos: s.TempoChanges, Markers: s.Markers}
+}
+
+// Tempos returns the tempo changes of a track from the tempo changes of a
+// score, which have their positions in quarter notes. Tempos are rounded to
+// the nearest beat per minute, and a tempo change replaces any earlier change
+// at the same position.
+func Tempos(tempos []encoding.TempoChange) []mml.TempoChange {
+	var changes []mml.TempoChange
+	for _, t := range tempos {
+		tc := mml.TempoChange{At: encoding.TimeAt(tempos, t.Beat), Tempo: int(math.Round(t.BPM))}
+		if n := len(changes); n > 0 && changes[n-1].At == tc.At {
+			changes[n-1] = tc
+			continue
+		}
+		changes = append(changes, tc)
+	}
+	return changes
+}
+
+type event struct {
+	tick int
+	data []byte
+}
+
+// rank orders the events that happen on the same tick. Meta events come
+// first, then note offs so that repeated notes are not cut off, and then note
+// ons.
+func (e event) rank() int {
+	switch e.data[0] & 0xF0 {
+	case 0x80:
+		return 1
+	case 0x90:
+		return 2
+	}
+	return 0
+}
+
+// Write writes the tracks as a Standard MIDI File. A single track is written
+// as a type 0 file and several tracks as a type 1 file. The tempo map of the
+// file is taken from the first track, and the notes of every track are placed
+// at the same time in milliseconds as they are performed.
+// Since FFXIV doesn't support sustained notes, each note lasts until the next
+// note (or chord) of the same track is played.
+func Write(w io.Writer, tracks []Track) error {
+	if len(tracks) == 0 {
+		return errors.New("no tracks to write")
+	}
+	if len(tracks) > 15 {
+		return errors.New("cannot write more than 15 tracks")
+	}
+	tempos := tracks[0].Tempos
+	if len(tempos) == 0 || tempos[0].At > 0 {
+		tempos = append([]mml.TempoChange{{At: 0, Tempo: 120}}, tempos...)
+	}
+
+	buf := new(bytes.Buffer)
+	format := uint16(0)
+	if len(tracks) > 1 {
+		format = 1
+	}
+	buf.WriteString("MThd")
+	_ = binary.Write(buf, binary.BigEndian, []uint16{0, 6, format, uint16(len(tracks)), TicksPerQuarter})
+
+	for i, t := range tracks {
+		channel := byte(i)
+		if channel >= 9 {
+			// Skip the percussion channel
+			channel++
+		}
+		var events []event
+		if t.Name != "" {
+			events = append(events, event{tick: 0, data: meta(0x03, []byte(t.Name))})
+		}
+		if i == 0 {
+			for _, tc := range tempos {
+				us := 60000000 / tc.Tempo
+				events = append(events, event{
+					tick: ticks(tempos, tc.At),
+					data: meta(0x51, []byte{byte(us >> 16), byte(us >> 8), byte(us)}),
+				})
+			}
+		}
+		for _, m := range t.Markers {
+			events = append(events, event{tick: ticks(tempos, m.At), data: meta(0x06, []byte(m.Name))})
+		}
+		end := ticks(tempos, t.Sequence.Length())
+		for _, n := range noteSpans(t.Sequence) {
+			on := ticks(tempos, n.on)
+			off := ticks(tempos, n.off)
+			if off <= on {
+				off = on + 1
+			}
+			if off > end {
+				end = off
+			}
+			key := byte(n.note) + NoteOffset
+			events = append(events,
+				event{tick: on, data: []byte{0x90 | channel, key, Velocity}},
+				event{tick: off, data: []byte{0x80 | channel, key, 0}},
+			)
+		}
+		sort.SliceStable(events, func(a, b int) bool {
+			if events[a].tick != events[b].tick {
+				return events[a].tick < events[b].tick
+			}
+			return events[a].rank() < events[b].rank()
+		})
+		events = append(events, event{tick: end, data: meta(0x2F, nil)})
+
+		trk := new(bytes.Buffer)
+		last := 0
+		for _, e := range events {
+			writeVarInt(trk, e.tick-last)
+			trk.Write(e.data)
+			last = e.tick
+		}
+		buf.WriteString("MTrk")
+		_ = binary.Write(buf, binary.BigEndian, uint32(trk.Len()))
+		buf.Write(trk.Bytes())
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type span struct {
+	note    encoding.Note
+	on, off time.Duration
+}
+
+// noteSpans returns when each note of the sequence starts and stops. Notes
+// that are played within encoding.ChordDelay of each other are part of the
+// same chord and all stop when the next note after the chord is played.
+func noteSpans(seq encoding.Sequence) []span {
+	notes := seq.Timeline()
+	spans := make([]span, len(notes))
+	end := seq.Length()
+	groupStart := 0
+	for i, n := range notes {
+		spans[i] = span{note: n.Note, on: n.At}
+		if i+1 < len(notes) && notes[i+1].At-n.At <= encoding.ChordDelay*time.Millisecond {
+			continue
+		}
+		off := end
+		if i+1 < len(notes) {
+			off = notes[i+1].At
+		}
+		for j := groupStart; j <= i; j++ {
+			spans[j].off = off
+		}
+		groupStart = i + 1
+	}
+	return spans
+}
+
+// ticks converts a point in time to MIDI ticks given the tempo changes
+func ticks(tempos []mml.TempoChange, at time.Duration) int {
+	total := 0.0
+	for i, tc := range tempos {
+		if tc.At >= at {
+			break
+		}
+		end := at
+		if i+1 < len(tempos) && tempos[i+1].At < at {
+			end = tempos[i+1].At
+		}
+		total += (end - tc.At).Minutes() * float64(tc.Tempo) * TicksPerQuarter
+	}
+	return int(math.Round(total))
+}
+
+func meta(typ byte, data []byte) []byte {
+	b := new(bytes.Buffer)
+	b.Write([]byte{0xFF, typ})
+	writeVarInt(b, len(data))
+	b.Write(data)
+	return b.Bytes()
+}
+
+// writeVarInt writes a variable length quantity as defined by the MIDI file
+// format
+func writeVarInt(b *bytes.Buffer, n int) {
+	var stack [5]byte
+	i := len(stack) - 1
+	stack[i] = byte(n & 0x7F)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		stack[i] = byte(n&0x7F) | 0x80
+	}
+	b.Write(stack[i:])
+}
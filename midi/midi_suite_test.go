@@ -0,0 +1,13 @@
+package midi_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMidi(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Midi Suite")
+}
@@ -0,0 +1,98 @@
+package midi_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/midi"
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Midi", func() {
+	Describe("Write", func() {
+		It("writes a single track as a type 0 file with the tempo", func() {
+			s := new(mml.State)
+			Expect(s.SetTempo(60)).To(Succeed())
+			Expect(s.EmitNote("C", "", 4, false)).To(Succeed())
+			Expect(s.SetTempo(120)).To(Succeed())
+			Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+			Expect(s.EmitNote("E", "", 4, false)).To(Succeed())
+
+			buf := new(bytes.Buffer)
+			Expect(midi.Write(buf, []midi.Track{midi.FromState("Lute", s)})).To(Succeed())
+			Expect(buf.Bytes()).To(Equal([]byte{
+				'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x01, 0xE0,
+				'M', 'T', 'r', 'k', 0, 0, 0, 52,
+				0x00, 0xFF, 0x03, 0x04, 'L', 'u', 't', 'e',
+				0x00, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40,
+				0x00, 0x90, 60, 100,
+				0x83, 0x60, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20,
+				0x00, 0x80, 60, 0,
+				0x00, 0x90, 60, 100,
+				0x13, 0x90, 64, 100,
+				0x83, 0x60, 0x80, 60, 0,
+				0x00, 0x80, 64, 0,
+				0x00, 0xFF, 0x2F, 0x00,
+			}))
+		})
+		It("writes several tracks as a type 1 file on separate channels", func() {
+			buf := new(bytes.Buffer)
+			Expect(midi.Write(buf, []midi.Track{
+				{Sequence: encoding.Sequence{encoding.Note(1), encoding.Delay(250), encoding.Delay(250)}},
+				{Sequence: encoding.Sequence{encoding.Delay(250), encoding.Delay(250), encoding.Note(37), encoding.Delay(250), encoding.Delay(250)}},
+			})).To(Succeed())
+			Expect(buf.Bytes()).To(Equal([]byte{
+				'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 2, 0x01, 0xE0,
+				'M', 'T', 'r', 'k', 0, 0, 0, 20,
+				0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20,
+				0x00, 0x90, 48, 100,
+				0x83, 0x60, 0x80, 48, 0,
+				0x00, 0xFF, 0x2F, 0x00,
+				'M', 'T', 'r', 'k', 0, 0, 0, 14,
+				0x83, 0x60, 0x91, 84, 100,
+				0x83, 0x60, 0x81, 84, 0,
+				0x00, 0xFF, 0x2F, 0x00,
+			}))
+		})
+		It("writes the markers of the first track as marker events", func() {
+			buf := new(bytes.Buffer)
+			Expect(midi.Write(buf, []midi.Track{{
+				Sequence: encoding.Sequence{encoding.Note(13), encoding.Delay(250), encoding.Delay(250), encoding.Note(15), encoding.Delay(250)},
+				Markers:  []mml.Marker{{Name: "verse"}, {Name: "chorus", At: 500 * time.Millisecond, Beats: 1}},
+			}})).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("\x00\xFF\x06\x05verse\x00\x90"))
+			Expect(buf.String()).To(ContainSubstring("\x83\x60\xFF\x06\x06chorus\x00\x80"))
+		})
+		It("errors if there are no tracks", func() {
+			Expect(midi.Write(new(bytes.Buffer), nil)).To(MatchError("no tracks to write"))
+		})
+	})
+	Describe("Tempos", func() {
+		It("places the tempo changes of a score at their times", func() {
+			Expect(midi.Tempos([]encoding.TempoChange{
+				{Beat: 0, BPM: 120}, {Beat: 0, BPM: 60}, {Beat: 2, BPM: 90.4},
+			})).To(Equal([]mml.TempoChange{
+				{At: 0, Tempo: 60}, {At: 2 * time.Second, Tempo: 90},
+			}))
+		})
+	})
+	Describe("FromState", func() {
+		It("uses the sequence, tempo changes, and markers of the state", func() {
+			s := &mml.State{
+				Sequence:     encoding.Sequence{encoding.Note(3)},
+				TempoChanges: []mml.TempoChange{{At: time.Second, Tempo: 80}},
+				Markers:      []mml.Marker{{Name: "intro"}},
+			}
+			Expect(midi.FromState("Harp", s)).To(Equal(midi.Track{
+				Name:     "Harp",
+				Sequence: s.Sequence,
+				Tempos:   s.TempoChanges,
+				Markers:  s.Markers,
+			}))
+		})
+	})
+})
@@ -13,6 +13,31 @@ func (n *NoteCommand) Execute(e Executor) error {
 	return e.EmitNote(n.Note, n.Modifier, n.Length, n.Dot)
 }
 
+// TieCommand extends the previous note if it has the same pitch, or emits
+// the note otherwise
+type TieCommand struct {
+	Note     string
+	Modifier string
+	Length   int
+	Dot      bool
+}
+
+// Execute ties the note to the previous note on the state
+func (t *TieCommand) Execute(e Executor) error {
+	return e.TieNote(t.Note, t.Modifier, t.Length, t.Dot)
+}
+
+// NoteNumberCommand emits a note by its MIDI note number with the default
+// length
+type NoteNumberCommand struct {
+	Number int
+}
+
+// Execute emits a note on the state
+func (n *NoteNumberCommand) Execute(e Executor) error {
+	return e.EmitNoteNumber(n.Number)
+}
+
 // RestCommand emits a rest with a certain length
 type RestCommand struct {
 	Length int
@@ -55,6 +80,36 @@ func (o *OctaveCommand) Execute(e Executor) error {
 	return e.SetOctave(o.Octave)
 }
 
+// KeyCommand sets the key signature
+type KeyCommand struct {
+	Key string
+}
+
+// Execute sets the key signature on the state
+func (k *KeyCommand) Execute(e Executor) error {
+	return e.SetKey(k.Key)
+}
+
+// InstrumentCommand selects the instrument that plays the following notes
+type InstrumentCommand struct {
+	Instrument int
+}
+
+// Execute sets the instrument on the state
+func (i *InstrumentCommand) Execute(e Executor) error {
+	return e.SetInstrument(i.Instrument)
+}
+
+// MarkerCommand is a rehearsal marker, like the start of a chorus
+type MarkerCommand struct {
+	Name string
+}
+
+// Execute adds the marker to the state
+func (m *MarkerCommand) Execute(e Executor) error {
+	return e.AddMarker(m.Name)
+}
+
 // OctaveUpCommand increments the octave
 type OctaveUpCommand struct{}
 
@@ -71,6 +126,18 @@ func (o *OctaveDownCommand) Execute(e Executor) error {
 	return e.SetOctave(e.CurrentOctave() - 1)
 }
 
+// VolumeCommand is the volume of the following notes. The volume of notes
+// can't be changed in game, so it does nothing, but the volume is kept so that
+// the command can be printed.
+type VolumeCommand struct {
+	Volume int
+}
+
+// Execute does nothing
+func (v *VolumeCommand) Execute(e Executor) error {
+	return nil
+}
+
 // NoOpCommand literally does nothing
 type NoOpCommand struct{}
 
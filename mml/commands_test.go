@@ -44,6 +44,56 @@ var _ = Describe("Commands", func() {
 			})
 		})
 	})
+	Describe("TieCommand", func() {
+		var c *mml.TieCommand
+		BeforeEach(func() {
+			c = &mml.TieCommand{
+				Note:     "A",
+				Modifier: "-",
+				Length:   8,
+				Dot:      true,
+			}
+		})
+		It("ties a note on the state", func() {
+			Expect(c.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.TieNoteCallCount()).To(Equal(1))
+			note, modifier, length, dot := fakeExecutor.TieNoteArgsForCall(0)
+			Expect(note).To(Equal("A"))
+			Expect(modifier).To(Equal("-"))
+			Expect(length).To(Equal(8))
+			Expect(dot).To(BeTrue())
+		})
+		Context("when the state emits an error", func() {
+			BeforeEach(func() {
+				fakeExecutor.TieNoteReturns(fooError)
+			})
+			It("command returns the same error", func() {
+				Expect(c.Execute(fakeExecutor)).To(MatchError(fooError))
+			})
+		})
+	})
+	Describe("NoteNumberCommand", func() {
+		var c *mml.NoteNumberCommand
+		BeforeEach(func() {
+			c = &mml.NoteNumberCommand{
+				Number: 60,
+			}
+		})
+		It("emits a note on the state", func() {
+			Expect(c.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.EmitNoteNumberCallCount()).To(Equal(1))
+			number := fakeExecutor.EmitNoteNumberArgsForCall(0)
+			Expect(number).To(Equal(60))
+		})
+		Context("when the state emits an error", func() {
+			BeforeEach(func() {
+				fakeExecutor.EmitNoteNumberReturns(fooError)
+			})
+			It("command returns the same error", func() {
+				Expect(c.Execute(fakeExecutor)).To(MatchError(fooError))
+			})
+		})
+	})
 	Describe("RestCommand", func() {
 		var c *mml.RestCommand
 		BeforeEach(func() {
@@ -136,6 +186,70 @@ var _ = Describe("Commands", func() {
 			})
 		})
 	})
+	Describe("KeyCommand", func() {
+		var c *mml.KeyCommand
+		BeforeEach(func() {
+			c = &mml.KeyCommand{
+				Key: "E-",
+			}
+		})
+		It("sets the key on the state", func() {
+			Expect(c.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.SetKeyCallCount()).To(Equal(1))
+			key := fakeExecutor.SetKeyArgsForCall(0)
+			Expect(key).To(Equal("E-"))
+		})
+		Context("when the state emits an error", func() {
+			BeforeEach(func() {
+				fakeExecutor.SetKeyReturns(fooError)
+			})
+			It("command returns the same error", func() {
+				Expect(c.Execute(fakeExecutor)).To(MatchError(fooError))
+			})
+		})
+	})
+	Describe("InstrumentCommand", func() {
+		var c *mml.InstrumentCommand
+		BeforeEach(func() {
+			c = &mml.InstrumentCommand{
+				Instrument: 5,
+			}
+		})
+		It("sets the instrument on the state", func() {
+			Expect(c.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.SetInstrumentCallCount()).To(Equal(1))
+			Expect(fakeExecutor.SetInstrumentArgsForCall(0)).To(Equal(5))
+		})
+		Context("when the state emits an error", func() {
+			BeforeEach(func() {
+				fakeExecutor.SetInstrumentReturns(fooError)
+			})
+			It("command returns the same error", func() {
+				Expect(c.Execute(fakeExecutor)).To(MatchError(fooError))
+			})
+		})
+	})
+	Describe("MarkerCommand", func() {
+		var c *mml.MarkerCommand
+		BeforeEach(func() {
+			c = &mml.MarkerCommand{
+				Name: "chorus",
+			}
+		})
+		It("adds the marker to the state", func() {
+			Expect(c.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.AddMarkerCallCount()).To(Equal(1))
+			Expect(fakeExecutor.AddMarkerArgsForCall(0)).To(Equal("chorus"))
+		})
+		Context("when the state emits an error", func() {
+			BeforeEach(func() {
+				fakeExecutor.AddMarkerReturns(fooError)
+			})
+			It("command returns the same error", func() {
+				Expect(c.Execute(fakeExecutor)).To(MatchError(fooError))
+			})
+		})
+	})
 	Describe("OctaveUpCommand", func() {
 		var c *mml.OctaveUpCommand
 		BeforeEach(func() {
@@ -178,6 +292,16 @@ var _ = Describe("Commands", func() {
 			})
 		})
 	})
+	Describe("VolumeCommand", func() {
+		var v *mml.VolumeCommand
+		BeforeEach(func() {
+			v = &mml.VolumeCommand{Volume: 127}
+		})
+		It("does nothing", func() {
+			Expect(v.Execute(fakeExecutor)).To(Succeed())
+			Expect(fakeExecutor.Invocations()).To(BeEmpty())
+		})
+	})
 	Describe("NoOpCommand", func() {
 		var n *mml.NoOpCommand
 		BeforeEach(func() {
@@ -0,0 +1,101 @@
+package mml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect describes the differences between the flavors of MML understood by
+// other games and tools. A dialect changes both which commands are recognized
+// by the scanner and how some commands are executed on the state.
+type Dialect struct {
+	Name string
+
+	// MinTempo and MaxTempo are the tempo limits (in BPM) of the tempo command
+	MinTempo int
+	MaxTempo int
+
+	// TieNotes makes `&` tie the following note to the previous note when they
+	// have the same pitch and play it otherwise. If false, `&` always turns
+	// the following note into a rest.
+	TieNotes bool
+	// CaretTies enables `^`, which extends the previous note by a length
+	CaretTies bool
+	// NoteNumbers enables the `n` command
+	NoteNumbers bool
+	// NoteNumberOffset is added to the number of an `n` command to get the
+	// MIDI note number
+	NoteNumberOffset int
+	// KeySignatures enables the `k` command
+	KeySignatures bool
+	// Instruments enables the `@` command, which selects an instrument
+	Instruments bool
+	// Markers enables `*` rehearsal markers, like `*chorus`
+	Markers bool
+}
+
+// Performgen is the default dialect, which accepts a superset of most other
+// dialects
+var Performgen = &Dialect{
+	Name:          "performgen",
+	MinTempo:      1,
+	MaxTempo:      900,
+	NoteNumbers:   true,
+	KeySignatures: true,
+	Instruments:   true,
+	Markers:       true,
+}
+
+// Mabinogi is the dialect understood by Mabinogi, where `n48` is `o4c`
+var Mabinogi = &Dialect{
+	Name:             "mabinogi",
+	MinTempo:         32,
+	MaxTempo:         255,
+	TieNotes:         true,
+	NoteNumbers:      true,
+	NoteNumberOffset: 12,
+}
+
+// ArcheAge is the dialect understood by ArcheAge, which has `^` ties but no
+// `n` command
+var ArcheAge = &Dialect{
+	Name:      "archeage",
+	MinTempo:  32,
+	MaxTempo:  255,
+	TieNotes:  true,
+	CaretTies: true,
+}
+
+// ThreeMLE is the dialect written by the 3MLE editor, which accepts both the
+// Mabinogi `n` command and ArcheAge `^` ties
+var ThreeMLE = &Dialect{
+	Name:             "3mle",
+	MinTempo:         32,
+	MaxTempo:         255,
+	TieNotes:         true,
+	CaretTies:        true,
+	NoteNumbers:      true,
+	NoteNumberOffset: 12,
+}
+
+// Dialects are the named dialect profiles
+var Dialects = map[string]*Dialect{
+	Performgen.Name: Performgen,
+	Mabinogi.Name:   Mabinogi,
+	ArcheAge.Name:   ArcheAge,
+	ThreeMLE.Name:   ThreeMLE,
+}
+
+// LookupDialect returns the dialect profile with the given name
+func LookupDialect(name string) (*Dialect, error) {
+	if d, ok := Dialects[strings.ToLower(name)]; ok {
+		return d, nil
+	}
+	var names []string
+	for n := range Dialects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown dialect: %s (expected one of %s)", name, strings.Join(names, ", "))
+}
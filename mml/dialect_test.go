@@ -0,0 +1,27 @@
+package mml_test
+
+import (
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LookupDialect", func() {
+	DescribeTable("returns the named dialect",
+		func(name string, expected *mml.Dialect) {
+			d, err := mml.LookupDialect(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(BeIdenticalTo(expected))
+		},
+		Entry("performgen", "performgen", mml.Performgen),
+		Entry("mabinogi", "Mabinogi", mml.Mabinogi),
+		Entry("archeage", "ArcheAge", mml.ArcheAge),
+		Entry("3mle", "3MLE", mml.ThreeMLE),
+	)
+	It("errors if the dialect is unknown", func() {
+		_, err := mml.LookupDialect("famitracker")
+		Expect(err).To(MatchError("unknown dialect: famitracker (expected one of 3mle, archeage, mabinogi, performgen)"))
+	})
+})
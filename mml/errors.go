@@ -0,0 +1,96 @@
+package mml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseError is returned by the parser when the input is not valid MML
+type ParseError struct {
+	Position Position
+	// Token is the text of the token where the error was found
+	Token string
+	// Command is the name of the command that was being parsed, if any
+	Command string
+	// Message describes what was wrong or what was expected instead
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e.Command != "" {
+		return fmt.Sprintf("%s command at %s: %s", e.Command, e.Position, e.Message)
+	}
+	return fmt.Sprintf("%s at %s", e.Message, e.Position)
+}
+
+// ExecError is returned when a command fails to execute on the state
+type ExecError struct {
+	Position Position
+	// Span is the part of the input that the command was parsed from
+	Span    Span
+	Command Command
+	Err     error
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("execution error at %s: %s", e.Position, e.Err)
+}
+
+// Unwrap returns the error returned by the executor, which is often a
+// *RangeError
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// RangeError is returned by the state when a value is outside of the range
+// that can be performed
+type RangeError struct {
+	// Name is what the value is: "note", "note number", "octave", "tempo", or
+	// "default length"
+	Name string
+	// Text is the value as it was written, like "d+" for a note
+	Text string
+	// Octave is the octave a note was played at
+	Octave int
+	// Instrument is the name of the instrument a note was played on, if one
+	// was selected
+	Instrument string
+	Value      int
+	Min        int
+	Max        int
+}
+
+func (e *RangeError) Error() string {
+	switch e.Name {
+	case "note":
+		if e.Instrument != "" {
+			return fmt.Sprintf("invalid note: %s at octave %d on %s", e.Text, e.Octave, e.Instrument)
+		}
+		return fmt.Sprintf("invalid note: %s at octave %d", e.Text, e.Octave)
+	case "note number":
+		if e.Instrument != "" {
+			return fmt.Sprintf("invalid note number: %d is out of range (%d-%d) on %s", e.Value, e.Min, e.Max, e.Instrument)
+		}
+		return fmt.Sprintf("invalid note number: %d is out of range (%d-%d)", e.Value, e.Min, e.Max)
+	case "octave":
+		var octaves []string
+		for o := e.Min; o <= e.Max; o++ {
+			octaves = append(octaves, strconv.Itoa(o))
+		}
+		if last := len(octaves) - 1; last > 0 {
+			octaves[last] = "or " + octaves[last]
+		}
+		return fmt.Sprintf("cannot set octave to anything other than %s", strings.Join(octaves, ", "))
+	case "default length":
+		if e.Value < 0 {
+			return "cannot set default length to less than 0"
+		} else if e.Value == 0 {
+			return "cannot set default length to 0"
+		}
+	}
+	if e.Value < e.Min {
+		return fmt.Sprintf("cannot set %s to lower than %d", e.Name, e.Min)
+	}
+	return fmt.Sprintf("cannot set %s to greater than %d", e.Name, e.Max)
+}
@@ -0,0 +1,78 @@
+package mml_test
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	Describe("ParseError", func() {
+		parse := func(input string) *mml.ParseError {
+			_, err := mml.NewParser(bytes.NewReader([]byte(input))).Parse()
+			var parseErr *mml.ParseError
+			Expect(errors.As(err, &parseErr)).To(BeTrue())
+			return parseErr
+		}
+
+		It("is returned for invalid tokens", func() {
+			err := parse("cd H")
+			Expect(err.Position).To(Equal(mml.Position{Line: 1, Column: 4}))
+			Expect(err.Token).To(Equal("H"))
+			Expect(err.Command).To(BeEmpty())
+			Expect(err).To(MatchError("invalid token 'H' at line 1, column 4"))
+		})
+
+		It("is returned for commands with missing arguments", func() {
+			err := parse("c\nt c")
+			Expect(err.Position).To(Equal(mml.Position{Line: 2, Column: 1}))
+			Expect(err.Token).To(Equal("t"))
+			Expect(err.Command).To(Equal("Tempo"))
+			Expect(err.Message).To(Equal("expected numeric argument"))
+			Expect(err).To(MatchError("Tempo command at line 2, column 1: expected numeric argument"))
+		})
+
+		It("is returned for numeric arguments that are too large", func() {
+			err := parse("c99999999999999999999")
+			Expect(err.Token).To(Equal("99999999999999999999"))
+			Expect(err.Message).To(ContainSubstring("value out of range"))
+		})
+	})
+
+	Describe("ExecError", func() {
+		It("wraps the error returned by the executor", func() {
+			rangeErr := &mml.RangeError{Name: "octave", Value: 7, Min: 3, Max: 6}
+			err := error(&mml.ExecError{
+				Position: mml.Position{Line: 3, Column: 5},
+				Command:  &mml.OctaveCommand{Octave: 7},
+				Err:      rangeErr,
+			})
+			Expect(err).To(MatchError("execution error at line 3, column 5: cannot set octave to anything other than 3, 4, 5, or 6"))
+
+			var target *mml.RangeError
+			Expect(errors.As(err, &target)).To(BeTrue())
+			Expect(target).To(BeIdenticalTo(rangeErr))
+		})
+	})
+
+	Describe("RangeError", func() {
+		DescribeTable("formats the error for the value",
+			func(err *mml.RangeError, expected string) {
+				Expect(err).To(MatchError(expected))
+			},
+			Entry("note", &mml.RangeError{Name: "note", Text: "d+", Octave: 6, Value: 40, Min: 1, Max: 37}, "invalid note: d+ at octave 6"),
+			Entry("note number", &mml.RangeError{Name: "note number", Value: 85, Min: 48, Max: 84}, "invalid note number: 85 is out of range (48-84)"),
+			Entry("octave", &mml.RangeError{Name: "octave", Value: 2, Min: 3, Max: 6}, "cannot set octave to anything other than 3, 4, 5, or 6"),
+			Entry("low tempo", &mml.RangeError{Name: "tempo", Value: 0, Min: 1, Max: 900}, "cannot set tempo to lower than 1"),
+			Entry("high tempo", &mml.RangeError{Name: "tempo", Value: 901, Min: 1, Max: 900}, "cannot set tempo to greater than 900"),
+			Entry("negative default length", &mml.RangeError{Name: "default length", Value: -1, Min: 1, Max: 64}, "cannot set default length to less than 0"),
+			Entry("zero default length", &mml.RangeError{Name: "default length", Value: 0, Min: 1, Max: 64}, "cannot set default length to 0"),
+			Entry("high default length", &mml.RangeError{Name: "default length", Value: 65, Min: 1, Max: 64}, "cannot set default length to greater than 64"),
+		)
+	})
+})
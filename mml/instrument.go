@@ -0,0 +1,123 @@
+package mml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instrument describes how one of the in-game instruments plays notes. The
+// state checks notes against the instrument that is selected with the `@`
+// command, or the instrument set on the state.
+type Instrument struct {
+	// Number is the number of the instrument in game, which is also the
+	// argument of the `@` command
+	Number int
+	Name   string
+
+	// Lowest and Highest are the MIDI note numbers of the lowest and highest
+	// notes that the instrument sounds. Every instrument is played with the
+	// same keys from `C (-1)` to `C (+2)`, but most of them sound one or two
+	// octaves higher or lower than the harp, which sounds from 48 (C3) to 84
+	// (C6).
+	Lowest  int
+	Highest int
+	// MinSpacing is the shortest time between two notes that the instrument
+	// still plays as separate notes. Instruments whose notes sustain need
+	// some time between notes for the next note to be heard.
+	MinSpacing time.Duration
+}
+
+// Shift returns the number of semitones that the notes the instrument sounds
+// are above the keys that play them
+func (i *Instrument) Shift() int {
+	return i.Lowest - (noteNumberOffset + 1)
+}
+
+// keys returns the number of keys that the instrument can play, starting from
+// `C (-1)`
+func (i *Instrument) keys() int {
+	return i.Highest - i.Lowest + 1
+}
+
+// defaultInstrument is used when no instrument is selected, and plays every
+// key at the pitch of the harp
+var defaultInstrument = &Instrument{Name: "Default", Lowest: 48, Highest: 84}
+
+// Instruments are the profiles of the in-game instruments, in order of their
+// numbers
+var Instruments = []*Instrument{
+	{Number: 1, Name: "Harp", Lowest: 48, Highest: 84},
+	{Number: 2, Name: "Piano", Lowest: 60, Highest: 96},
+	{Number: 3, Name: "Lute", Lowest: 36, Highest: 72},
+	{Number: 4, Name: "Fiddle", Lowest: 36, Highest: 72},
+	{Number: 5, Name: "Flute", Lowest: 72, Highest: 108, MinSpacing: 50 * time.Millisecond},
+	{Number: 6, Name: "Oboe", Lowest: 60, Highest: 96, MinSpacing: 50 * time.Millisecond},
+	{Number: 7, Name: "Clarinet", Lowest: 48, Highest: 84, MinSpacing: 50 * time.Millisecond},
+	{Number: 8, Name: "Fife", Lowest: 72, Highest: 108, MinSpacing: 50 * time.Millisecond},
+	{Number: 9, Name: "Panpipes", Lowest: 60, Highest: 96, MinSpacing: 50 * time.Millisecond},
+	{Number: 10, Name: "Timpani", Lowest: 36, Highest: 72},
+	{Number: 11, Name: "Bongo", Lowest: 48, Highest: 84},
+	{Number: 12, Name: "Bass Drum", Lowest: 36, Highest: 72},
+	{Number: 13, Name: "Snare Drum", Lowest: 48, Highest: 84},
+	{Number: 14, Name: "Cymbal", Lowest: 48, Highest: 84},
+	{Number: 15, Name: "Trumpet", Lowest: 48, Highest: 84, MinSpacing: 50 * time.Millisecond},
+	{Number: 16, Name: "Trombone", Lowest: 36, Highest: 72, MinSpacing: 50 * time.Millisecond},
+	{Number: 17, Name: "Tuba", Lowest: 24, Highest: 60, MinSpacing: 50 * time.Millisecond},
+	{Number: 18, Name: "Horn", Lowest: 36, Highest: 72, MinSpacing: 50 * time.Millisecond},
+	{Number: 19, Name: "Saxophone", Lowest: 48, Highest: 84, MinSpacing: 50 * time.Millisecond},
+	{Number: 20, Name: "Violin", Lowest: 48, Highest: 84, MinSpacing: 50 * time.Millisecond},
+	{Number: 21, Name: "Viola", Lowest: 48, Highest: 84, MinSpacing: 50 * time.Millisecond},
+	{Number: 22, Name: "Cello", Lowest: 36, Highest: 72, MinSpacing: 50 * time.Millisecond},
+	{Number: 23, Name: "Double Bass", Lowest: 24, Highest: 60, MinSpacing: 50 * time.Millisecond},
+	{Number: 24, Name: "Electric Guitar: Overdriven", Lowest: 36, Highest: 72, MinSpacing: 50 * time.Millisecond},
+	{Number: 25, Name: "Electric Guitar: Clean", Lowest: 36, Highest: 72, MinSpacing: 50 * time.Millisecond},
+	{Number: 26, Name: "Electric Guitar: Muted", Lowest: 36, Highest: 72},
+	{Number: 27, Name: "Electric Guitar: Power Chords", Lowest: 24, Highest: 60, MinSpacing: 50 * time.Millisecond},
+	{Number: 28, Name: "Electric Guitar: Special", Lowest: 48, Highest: 84},
+}
+
+// LookupInstrument returns the instrument profile with the given number or
+// name. Names are matched ignoring case, spaces, and punctuation, so
+// "double bass" and "DoubleBass" are the same instrument.
+func LookupInstrument(name string) (*Instrument, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if i := instrumentNumber(n); i != nil {
+			return i, nil
+		}
+		return nil, fmt.Errorf("unknown instrument number: %d", n)
+	}
+	for _, i := range Instruments {
+		if instrumentKey(i.Name) == instrumentKey(name) {
+			return i, nil
+		}
+	}
+	var names []string
+	for _, i := range Instruments {
+		names = append(names, i.Name)
+	}
+	return nil, fmt.Errorf("unknown instrument: %s (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+func instrumentNumber(n int) *Instrument {
+	for _, i := range Instruments {
+		if i.Number == n {
+			return i
+		}
+	}
+	return nil
+}
+
+// instrumentKey returns the lowercase letters of an instrument name
+func instrumentKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, name)
+}
@@ -0,0 +1,47 @@
+package mml_test
+
+import (
+	"github.com/ff14wed/performgen/mml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instrument", func() {
+	It("numbers the instruments in order", func() {
+		for i, instrument := range mml.Instruments {
+			Expect(instrument.Number).To(Equal(i + 1))
+			Expect(instrument.Highest - instrument.Lowest).To(Equal(36))
+			Expect(instrument.Shift() % 12).To(BeZero())
+		}
+	})
+	Describe("Shift", func() {
+		It("returns how far above the keys the instrument sounds", func() {
+			for name, shift := range map[string]int{"harp": 0, "flute": 24, "tuba": -24} {
+				i, err := mml.LookupInstrument(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(i.Shift()).To(Equal(shift))
+			}
+		})
+	})
+	Describe("LookupInstrument", func() {
+		It("finds instruments by name", func() {
+			i, err := mml.LookupInstrument("double bass")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(i.Number).To(Equal(23))
+			i, err = mml.LookupInstrument("ElectricGuitar-Clean")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(i.Name).To(Equal("Electric Guitar: Clean"))
+		})
+		It("finds instruments by number", func() {
+			i, err := mml.LookupInstrument("5")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(i.Name).To(Equal("Flute"))
+			_, err = mml.LookupInstrument("42")
+			Expect(err).To(MatchError("unknown instrument number: 42"))
+		})
+		It("errors for unknown instruments", func() {
+			_, err := mml.LookupInstrument("kazoo")
+			Expect(err).To(MatchError(HavePrefix("unknown instrument: kazoo (expected one of Harp, Piano, ")))
+		})
+	})
+})
@@ -8,13 +8,34 @@ import (
 )
 
 type Executor struct {
-	EmitNoteStub        func(note string, modifier string, length int, dot bool) error
+	AddMarkerStub        func(string) error
+	addMarkerMutex       sync.RWMutex
+	addMarkerArgsForCall []struct {
+		arg1 string
+	}
+	addMarkerReturns struct {
+		result1 error
+	}
+	addMarkerReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CurrentOctaveStub        func() int
+	currentOctaveMutex       sync.RWMutex
+	currentOctaveArgsForCall []struct {
+	}
+	currentOctaveReturns struct {
+		result1 int
+	}
+	currentOctaveReturnsOnCall map[int]struct {
+		result1 int
+	}
+	EmitNoteStub        func(string, string, int, bool) error
 	emitNoteMutex       sync.RWMutex
 	emitNoteArgsForCall []struct {
-		note     string
-		modifier string
-		length   int
-		dot      bool
+		arg1 string
+		arg2 string
+		arg3 int
+		arg4 bool
 	}
 	emitNoteReturns struct {
 		result1 error
@@ -22,34 +43,34 @@ type Executor struct {
 	emitNoteReturnsOnCall map[int]struct {
 		result1 error
 	}
-	EmitRestStub        func(length int, dot bool) error
-	emitRestMutex       sync.RWMutex
-	emitRestArgsForCall []struct {
-		length int
-		dot    bool
+	EmitNoteNumberStub        func(int) error
+	emitNoteNumberMutex       sync.RWMutex
+	emitNoteNumberArgsForCall []struct {
+		arg1 int
 	}
-	emitRestReturns struct {
+	emitNoteNumberReturns struct {
 		result1 error
 	}
-	emitRestReturnsOnCall map[int]struct {
+	emitNoteNumberReturnsOnCall map[int]struct {
 		result1 error
 	}
-	SetTempoStub        func(t int) error
-	setTempoMutex       sync.RWMutex
-	setTempoArgsForCall []struct {
-		t int
+	EmitRestStub        func(int, bool) error
+	emitRestMutex       sync.RWMutex
+	emitRestArgsForCall []struct {
+		arg1 int
+		arg2 bool
 	}
-	setTempoReturns struct {
+	emitRestReturns struct {
 		result1 error
 	}
-	setTempoReturnsOnCall map[int]struct {
+	emitRestReturnsOnCall map[int]struct {
 		result1 error
 	}
-	SetDefaultLengthStub        func(l int, dot bool) error
+	SetDefaultLengthStub        func(int, bool) error
 	setDefaultLengthMutex       sync.RWMutex
 	setDefaultLengthArgsForCall []struct {
-		l   int
-		dot bool
+		arg1 int
+		arg2 bool
 	}
 	setDefaultLengthReturns struct {
 		result1 error
@@ -57,10 +78,32 @@ type Executor struct {
 	setDefaultLengthReturnsOnCall map[int]struct {
 		result1 error
 	}
-	SetOctaveStub        func(o int) error
+	SetInstrumentStub        func(int) error
+	setInstrumentMutex       sync.RWMutex
+	setInstrumentArgsForCall []struct {
+		arg1 int
+	}
+	setInstrumentReturns struct {
+		result1 error
+	}
+	setInstrumentReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SetKeyStub        func(string) error
+	setKeyMutex       sync.RWMutex
+	setKeyArgsForCall []struct {
+		arg1 string
+	}
+	setKeyReturns struct {
+		result1 error
+	}
+	setKeyReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SetOctaveStub        func(int) error
 	setOctaveMutex       sync.RWMutex
 	setOctaveArgsForCall []struct {
-		o int
+		arg1 int
 	}
 	setOctaveReturns struct {
 		result1 error
@@ -68,37 +111,169 @@ type Executor struct {
 	setOctaveReturnsOnCall map[int]struct {
 		result1 error
 	}
-	CurrentOctaveStub        func() int
-	currentOctaveMutex       sync.RWMutex
-	currentOctaveArgsForCall []struct{}
-	currentOctaveReturns     struct {
-		result1 int
+	SetTempoStub        func(int) error
+	setTempoMutex       sync.RWMutex
+	setTempoArgsForCall []struct {
+		arg1 int
 	}
-	currentOctaveReturnsOnCall map[int]struct {
-		result1 int
+	setTempoReturns struct {
+		result1 error
+	}
+	setTempoReturnsOnCall map[int]struct {
+		result1 error
+	}
+	TieNoteStub        func(string, string, int, bool) error
+	tieNoteMutex       sync.RWMutex
+	tieNoteArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 int
+		arg4 bool
+	}
+	tieNoteReturns struct {
+		result1 error
+	}
+	tieNoteReturnsOnCall map[int]struct {
+		result1 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *Executor) EmitNote(note string, modifier string, length int, dot bool) error {
+func (fake *Executor) AddMarker(arg1 string) error {
+	fake.addMarkerMutex.Lock()
+	ret, specificReturn := fake.addMarkerReturnsOnCall[len(fake.addMarkerArgsForCall)]
+	fake.addMarkerArgsForCall = append(fake.addMarkerArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.AddMarkerStub
+	fakeReturns := fake.addMarkerReturns
+	fake.recordInvocation("AddMarker", []interface{}{arg1})
+	fake.addMarkerMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Executor) AddMarkerCallCount() int {
+	fake.addMarkerMutex.RLock()
+	defer fake.addMarkerMutex.RUnlock()
+	return len(fake.addMarkerArgsForCall)
+}
+
+func (fake *Executor) AddMarkerCalls(stub func(string) error) {
+	fake.addMarkerMutex.Lock()
+	defer fake.addMarkerMutex.Unlock()
+	fake.AddMarkerStub = stub
+}
+
+func (fake *Executor) AddMarkerArgsForCall(i int) string {
+	fake.addMarkerMutex.RLock()
+	defer fake.addMarkerMutex.RUnlock()
+	argsForCall := fake.addMarkerArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Executor) AddMarkerReturns(result1 error) {
+	fake.addMarkerMutex.Lock()
+	defer fake.addMarkerMutex.Unlock()
+	fake.AddMarkerStub = nil
+	fake.addMarkerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) AddMarkerReturnsOnCall(i int, result1 error) {
+	fake.addMarkerMutex.Lock()
+	defer fake.addMarkerMutex.Unlock()
+	fake.AddMarkerStub = nil
+	if fake.addMarkerReturnsOnCall == nil {
+		fake.addMarkerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addMarkerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) CurrentOctave() int {
+	fake.currentOctaveMutex.Lock()
+	ret, specificReturn := fake.currentOctaveReturnsOnCall[len(fake.currentOctaveArgsForCall)]
+	fake.currentOctaveArgsForCall = append(fake.currentOctaveArgsForCall, struct {
+	}{})
+	stub := fake.CurrentOctaveStub
+	fakeReturns := fake.currentOctaveReturns
+	fake.recordInvocation("CurrentOctave", []interface{}{})
+	fake.currentOctaveMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Executor) CurrentOctaveCallCount() int {
+	fake.currentOctaveMutex.RLock()
+	defer fake.currentOctaveMutex.RUnlock()
+	return len(fake.currentOctaveArgsForCall)
+}
+
+func (fake *Executor) CurrentOctaveCalls(stub func() int) {
+	fake.currentOctaveMutex.Lock()
+	defer fake.currentOctaveMutex.Unlock()
+	fake.CurrentOctaveStub = stub
+}
+
+func (fake *Executor) CurrentOctaveReturns(result1 int) {
+	fake.currentOctaveMutex.Lock()
+	defer fake.currentOctaveMutex.Unlock()
+	fake.CurrentOctaveStub = nil
+	fake.currentOctaveReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *Executor) CurrentOctaveReturnsOnCall(i int, result1 int) {
+	fake.currentOctaveMutex.Lock()
+	defer fake.currentOctaveMutex.Unlock()
+	fake.CurrentOctaveStub = nil
+	if fake.currentOctaveReturnsOnCall == nil {
+		fake.currentOctaveReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.currentOctaveReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *Executor) EmitNote(arg1 string, arg2 string, arg3 int, arg4 bool) error {
 	fake.emitNoteMutex.Lock()
 	ret, specificReturn := fake.emitNoteReturnsOnCall[len(fake.emitNoteArgsForCall)]
 	fake.emitNoteArgsForCall = append(fake.emitNoteArgsForCall, struct {
-		note     string
-		modifier string
-		length   int
-		dot      bool
-	}{note, modifier, length, dot})
-	fake.recordInvocation("EmitNote", []interface{}{note, modifier, length, dot})
+		arg1 string
+		arg2 string
+		arg3 int
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.EmitNoteStub
+	fakeReturns := fake.emitNoteReturns
+	fake.recordInvocation("EmitNote", []interface{}{arg1, arg2, arg3, arg4})
 	fake.emitNoteMutex.Unlock()
-	if fake.EmitNoteStub != nil {
-		return fake.EmitNoteStub(note, modifier, length, dot)
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.emitNoteReturns.result1
+	return fakeReturns.result1
 }
 
 func (fake *Executor) EmitNoteCallCount() int {
@@ -107,13 +282,22 @@ func (fake *Executor) EmitNoteCallCount() int {
 	return len(fake.emitNoteArgsForCall)
 }
 
+func (fake *Executor) EmitNoteCalls(stub func(string, string, int, bool) error) {
+	fake.emitNoteMutex.Lock()
+	defer fake.emitNoteMutex.Unlock()
+	fake.EmitNoteStub = stub
+}
+
 func (fake *Executor) EmitNoteArgsForCall(i int) (string, string, int, bool) {
 	fake.emitNoteMutex.RLock()
 	defer fake.emitNoteMutex.RUnlock()
-	return fake.emitNoteArgsForCall[i].note, fake.emitNoteArgsForCall[i].modifier, fake.emitNoteArgsForCall[i].length, fake.emitNoteArgsForCall[i].dot
+	argsForCall := fake.emitNoteArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *Executor) EmitNoteReturns(result1 error) {
+	fake.emitNoteMutex.Lock()
+	defer fake.emitNoteMutex.Unlock()
 	fake.EmitNoteStub = nil
 	fake.emitNoteReturns = struct {
 		result1 error
@@ -121,6 +305,8 @@ func (fake *Executor) EmitNoteReturns(result1 error) {
 }
 
 func (fake *Executor) EmitNoteReturnsOnCall(i int, result1 error) {
+	fake.emitNoteMutex.Lock()
+	defer fake.emitNoteMutex.Unlock()
 	fake.EmitNoteStub = nil
 	if fake.emitNoteReturnsOnCall == nil {
 		fake.emitNoteReturnsOnCall = make(map[int]struct {
@@ -132,119 +318,147 @@ func (fake *Executor) EmitNoteReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *Executor) EmitRest(length int, dot bool) error {
-	fake.emitRestMutex.Lock()
-	ret, specificReturn := fake.emitRestReturnsOnCall[len(fake.emitRestArgsForCall)]
-	fake.emitRestArgsForCall = append(fake.emitRestArgsForCall, struct {
-		length int
-		dot    bool
-	}{length, dot})
-	fake.recordInvocation("EmitRest", []interface{}{length, dot})
-	fake.emitRestMutex.Unlock()
-	if fake.EmitRestStub != nil {
-		return fake.EmitRestStub(length, dot)
+func (fake *Executor) EmitNoteNumber(arg1 int) error {
+	fake.emitNoteNumberMutex.Lock()
+	ret, specificReturn := fake.emitNoteNumberReturnsOnCall[len(fake.emitNoteNumberArgsForCall)]
+	fake.emitNoteNumberArgsForCall = append(fake.emitNoteNumberArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.EmitNoteNumberStub
+	fakeReturns := fake.emitNoteNumberReturns
+	fake.recordInvocation("EmitNoteNumber", []interface{}{arg1})
+	fake.emitNoteNumberMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.emitRestReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *Executor) EmitRestCallCount() int {
-	fake.emitRestMutex.RLock()
-	defer fake.emitRestMutex.RUnlock()
-	return len(fake.emitRestArgsForCall)
+func (fake *Executor) EmitNoteNumberCallCount() int {
+	fake.emitNoteNumberMutex.RLock()
+	defer fake.emitNoteNumberMutex.RUnlock()
+	return len(fake.emitNoteNumberArgsForCall)
 }
 
-func (fake *Executor) EmitRestArgsForCall(i int) (int, bool) {
-	fake.emitRestMutex.RLock()
-	defer fake.emitRestMutex.RUnlock()
-	return fake.emitRestArgsForCall[i].length, fake.emitRestArgsForCall[i].dot
+func (fake *Executor) EmitNoteNumberCalls(stub func(int) error) {
+	fake.emitNoteNumberMutex.Lock()
+	defer fake.emitNoteNumberMutex.Unlock()
+	fake.EmitNoteNumberStub = stub
 }
 
-func (fake *Executor) EmitRestReturns(result1 error) {
-	fake.EmitRestStub = nil
-	fake.emitRestReturns = struct {
+func (fake *Executor) EmitNoteNumberArgsForCall(i int) int {
+	fake.emitNoteNumberMutex.RLock()
+	defer fake.emitNoteNumberMutex.RUnlock()
+	argsForCall := fake.emitNoteNumberArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Executor) EmitNoteNumberReturns(result1 error) {
+	fake.emitNoteNumberMutex.Lock()
+	defer fake.emitNoteNumberMutex.Unlock()
+	fake.EmitNoteNumberStub = nil
+	fake.emitNoteNumberReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *Executor) EmitRestReturnsOnCall(i int, result1 error) {
-	fake.EmitRestStub = nil
-	if fake.emitRestReturnsOnCall == nil {
-		fake.emitRestReturnsOnCall = make(map[int]struct {
+func (fake *Executor) EmitNoteNumberReturnsOnCall(i int, result1 error) {
+	fake.emitNoteNumberMutex.Lock()
+	defer fake.emitNoteNumberMutex.Unlock()
+	fake.EmitNoteNumberStub = nil
+	if fake.emitNoteNumberReturnsOnCall == nil {
+		fake.emitNoteNumberReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.emitRestReturnsOnCall[i] = struct {
+	fake.emitNoteNumberReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *Executor) SetTempo(t int) error {
-	fake.setTempoMutex.Lock()
-	ret, specificReturn := fake.setTempoReturnsOnCall[len(fake.setTempoArgsForCall)]
-	fake.setTempoArgsForCall = append(fake.setTempoArgsForCall, struct {
-		t int
-	}{t})
-	fake.recordInvocation("SetTempo", []interface{}{t})
-	fake.setTempoMutex.Unlock()
-	if fake.SetTempoStub != nil {
-		return fake.SetTempoStub(t)
+func (fake *Executor) EmitRest(arg1 int, arg2 bool) error {
+	fake.emitRestMutex.Lock()
+	ret, specificReturn := fake.emitRestReturnsOnCall[len(fake.emitRestArgsForCall)]
+	fake.emitRestArgsForCall = append(fake.emitRestArgsForCall, struct {
+		arg1 int
+		arg2 bool
+	}{arg1, arg2})
+	stub := fake.EmitRestStub
+	fakeReturns := fake.emitRestReturns
+	fake.recordInvocation("EmitRest", []interface{}{arg1, arg2})
+	fake.emitRestMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.setTempoReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *Executor) SetTempoCallCount() int {
-	fake.setTempoMutex.RLock()
-	defer fake.setTempoMutex.RUnlock()
-	return len(fake.setTempoArgsForCall)
+func (fake *Executor) EmitRestCallCount() int {
+	fake.emitRestMutex.RLock()
+	defer fake.emitRestMutex.RUnlock()
+	return len(fake.emitRestArgsForCall)
 }
 
-func (fake *Executor) SetTempoArgsForCall(i int) int {
-	fake.setTempoMutex.RLock()
-	defer fake.setTempoMutex.RUnlock()
-	return fake.setTempoArgsForCall[i].t
+func (fake *Executor) EmitRestCalls(stub func(int, bool) error) {
+	fake.emitRestMutex.Lock()
+	defer fake.emitRestMutex.Unlock()
+	fake.EmitRestStub = stub
 }
 
-func (fake *Executor) SetTempoReturns(result1 error) {
-	fake.SetTempoStub = nil
-	fake.setTempoReturns = struct {
+func (fake *Executor) EmitRestArgsForCall(i int) (int, bool) {
+	fake.emitRestMutex.RLock()
+	defer fake.emitRestMutex.RUnlock()
+	argsForCall := fake.emitRestArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Executor) EmitRestReturns(result1 error) {
+	fake.emitRestMutex.Lock()
+	defer fake.emitRestMutex.Unlock()
+	fake.EmitRestStub = nil
+	fake.emitRestReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *Executor) SetTempoReturnsOnCall(i int, result1 error) {
-	fake.SetTempoStub = nil
-	if fake.setTempoReturnsOnCall == nil {
-		fake.setTempoReturnsOnCall = make(map[int]struct {
+func (fake *Executor) EmitRestReturnsOnCall(i int, result1 error) {
+	fake.emitRestMutex.Lock()
+	defer fake.emitRestMutex.Unlock()
+	fake.EmitRestStub = nil
+	if fake.emitRestReturnsOnCall == nil {
+		fake.emitRestReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.setTempoReturnsOnCall[i] = struct {
+	fake.emitRestReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *Executor) SetDefaultLength(l int, dot bool) error {
+func (fake *Executor) SetDefaultLength(arg1 int, arg2 bool) error {
 	fake.setDefaultLengthMutex.Lock()
 	ret, specificReturn := fake.setDefaultLengthReturnsOnCall[len(fake.setDefaultLengthArgsForCall)]
 	fake.setDefaultLengthArgsForCall = append(fake.setDefaultLengthArgsForCall, struct {
-		l   int
-		dot bool
-	}{l, dot})
-	fake.recordInvocation("SetDefaultLength", []interface{}{l, dot})
+		arg1 int
+		arg2 bool
+	}{arg1, arg2})
+	stub := fake.SetDefaultLengthStub
+	fakeReturns := fake.setDefaultLengthReturns
+	fake.recordInvocation("SetDefaultLength", []interface{}{arg1, arg2})
 	fake.setDefaultLengthMutex.Unlock()
-	if fake.SetDefaultLengthStub != nil {
-		return fake.SetDefaultLengthStub(l, dot)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.setDefaultLengthReturns.result1
+	return fakeReturns.result1
 }
 
 func (fake *Executor) SetDefaultLengthCallCount() int {
@@ -253,13 +467,22 @@ func (fake *Executor) SetDefaultLengthCallCount() int {
 	return len(fake.setDefaultLengthArgsForCall)
 }
 
+func (fake *Executor) SetDefaultLengthCalls(stub func(int, bool) error) {
+	fake.setDefaultLengthMutex.Lock()
+	defer fake.setDefaultLengthMutex.Unlock()
+	fake.SetDefaultLengthStub = stub
+}
+
 func (fake *Executor) SetDefaultLengthArgsForCall(i int) (int, bool) {
 	fake.setDefaultLengthMutex.RLock()
 	defer fake.setDefaultLengthMutex.RUnlock()
-	return fake.setDefaultLengthArgsForCall[i].l, fake.setDefaultLengthArgsForCall[i].dot
+	argsForCall := fake.setDefaultLengthArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *Executor) SetDefaultLengthReturns(result1 error) {
+	fake.setDefaultLengthMutex.Lock()
+	defer fake.setDefaultLengthMutex.Unlock()
 	fake.SetDefaultLengthStub = nil
 	fake.setDefaultLengthReturns = struct {
 		result1 error
@@ -267,6 +490,8 @@ func (fake *Executor) SetDefaultLengthReturns(result1 error) {
 }
 
 func (fake *Executor) SetDefaultLengthReturnsOnCall(i int, result1 error) {
+	fake.setDefaultLengthMutex.Lock()
+	defer fake.setDefaultLengthMutex.Unlock()
 	fake.SetDefaultLengthStub = nil
 	if fake.setDefaultLengthReturnsOnCall == nil {
 		fake.setDefaultLengthReturnsOnCall = make(map[int]struct {
@@ -278,21 +503,145 @@ func (fake *Executor) SetDefaultLengthReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *Executor) SetOctave(o int) error {
+func (fake *Executor) SetInstrument(arg1 int) error {
+	fake.setInstrumentMutex.Lock()
+	ret, specificReturn := fake.setInstrumentReturnsOnCall[len(fake.setInstrumentArgsForCall)]
+	fake.setInstrumentArgsForCall = append(fake.setInstrumentArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.SetInstrumentStub
+	fakeReturns := fake.setInstrumentReturns
+	fake.recordInvocation("SetInstrument", []interface{}{arg1})
+	fake.setInstrumentMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Executor) SetInstrumentCallCount() int {
+	fake.setInstrumentMutex.RLock()
+	defer fake.setInstrumentMutex.RUnlock()
+	return len(fake.setInstrumentArgsForCall)
+}
+
+func (fake *Executor) SetInstrumentCalls(stub func(int) error) {
+	fake.setInstrumentMutex.Lock()
+	defer fake.setInstrumentMutex.Unlock()
+	fake.SetInstrumentStub = stub
+}
+
+func (fake *Executor) SetInstrumentArgsForCall(i int) int {
+	fake.setInstrumentMutex.RLock()
+	defer fake.setInstrumentMutex.RUnlock()
+	argsForCall := fake.setInstrumentArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Executor) SetInstrumentReturns(result1 error) {
+	fake.setInstrumentMutex.Lock()
+	defer fake.setInstrumentMutex.Unlock()
+	fake.SetInstrumentStub = nil
+	fake.setInstrumentReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) SetInstrumentReturnsOnCall(i int, result1 error) {
+	fake.setInstrumentMutex.Lock()
+	defer fake.setInstrumentMutex.Unlock()
+	fake.SetInstrumentStub = nil
+	if fake.setInstrumentReturnsOnCall == nil {
+		fake.setInstrumentReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setInstrumentReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) SetKey(arg1 string) error {
+	fake.setKeyMutex.Lock()
+	ret, specificReturn := fake.setKeyReturnsOnCall[len(fake.setKeyArgsForCall)]
+	fake.setKeyArgsForCall = append(fake.setKeyArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.SetKeyStub
+	fakeReturns := fake.setKeyReturns
+	fake.recordInvocation("SetKey", []interface{}{arg1})
+	fake.setKeyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Executor) SetKeyCallCount() int {
+	fake.setKeyMutex.RLock()
+	defer fake.setKeyMutex.RUnlock()
+	return len(fake.setKeyArgsForCall)
+}
+
+func (fake *Executor) SetKeyCalls(stub func(string) error) {
+	fake.setKeyMutex.Lock()
+	defer fake.setKeyMutex.Unlock()
+	fake.SetKeyStub = stub
+}
+
+func (fake *Executor) SetKeyArgsForCall(i int) string {
+	fake.setKeyMutex.RLock()
+	defer fake.setKeyMutex.RUnlock()
+	argsForCall := fake.setKeyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Executor) SetKeyReturns(result1 error) {
+	fake.setKeyMutex.Lock()
+	defer fake.setKeyMutex.Unlock()
+	fake.SetKeyStub = nil
+	fake.setKeyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) SetKeyReturnsOnCall(i int, result1 error) {
+	fake.setKeyMutex.Lock()
+	defer fake.setKeyMutex.Unlock()
+	fake.SetKeyStub = nil
+	if fake.setKeyReturnsOnCall == nil {
+		fake.setKeyReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setKeyReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) SetOctave(arg1 int) error {
 	fake.setOctaveMutex.Lock()
 	ret, specificReturn := fake.setOctaveReturnsOnCall[len(fake.setOctaveArgsForCall)]
 	fake.setOctaveArgsForCall = append(fake.setOctaveArgsForCall, struct {
-		o int
-	}{o})
-	fake.recordInvocation("SetOctave", []interface{}{o})
+		arg1 int
+	}{arg1})
+	stub := fake.SetOctaveStub
+	fakeReturns := fake.setOctaveReturns
+	fake.recordInvocation("SetOctave", []interface{}{arg1})
 	fake.setOctaveMutex.Unlock()
-	if fake.SetOctaveStub != nil {
-		return fake.SetOctaveStub(o)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.setOctaveReturns.result1
+	return fakeReturns.result1
 }
 
 func (fake *Executor) SetOctaveCallCount() int {
@@ -301,13 +650,22 @@ func (fake *Executor) SetOctaveCallCount() int {
 	return len(fake.setOctaveArgsForCall)
 }
 
+func (fake *Executor) SetOctaveCalls(stub func(int) error) {
+	fake.setOctaveMutex.Lock()
+	defer fake.setOctaveMutex.Unlock()
+	fake.SetOctaveStub = stub
+}
+
 func (fake *Executor) SetOctaveArgsForCall(i int) int {
 	fake.setOctaveMutex.RLock()
 	defer fake.setOctaveMutex.RUnlock()
-	return fake.setOctaveArgsForCall[i].o
+	argsForCall := fake.setOctaveArgsForCall[i]
+	return argsForCall.arg1
 }
 
 func (fake *Executor) SetOctaveReturns(result1 error) {
+	fake.setOctaveMutex.Lock()
+	defer fake.setOctaveMutex.Unlock()
 	fake.SetOctaveStub = nil
 	fake.setOctaveReturns = struct {
 		result1 error
@@ -315,6 +673,8 @@ func (fake *Executor) SetOctaveReturns(result1 error) {
 }
 
 func (fake *Executor) SetOctaveReturnsOnCall(i int, result1 error) {
+	fake.setOctaveMutex.Lock()
+	defer fake.setOctaveMutex.Unlock()
 	fake.SetOctaveStub = nil
 	if fake.setOctaveReturnsOnCall == nil {
 		fake.setOctaveReturnsOnCall = make(map[int]struct {
@@ -326,61 +686,156 @@ func (fake *Executor) SetOctaveReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *Executor) CurrentOctave() int {
-	fake.currentOctaveMutex.Lock()
-	ret, specificReturn := fake.currentOctaveReturnsOnCall[len(fake.currentOctaveArgsForCall)]
-	fake.currentOctaveArgsForCall = append(fake.currentOctaveArgsForCall, struct{}{})
-	fake.recordInvocation("CurrentOctave", []interface{}{})
-	fake.currentOctaveMutex.Unlock()
-	if fake.CurrentOctaveStub != nil {
-		return fake.CurrentOctaveStub()
+func (fake *Executor) SetTempo(arg1 int) error {
+	fake.setTempoMutex.Lock()
+	ret, specificReturn := fake.setTempoReturnsOnCall[len(fake.setTempoArgsForCall)]
+	fake.setTempoArgsForCall = append(fake.setTempoArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.SetTempoStub
+	fakeReturns := fake.setTempoReturns
+	fake.recordInvocation("SetTempo", []interface{}{arg1})
+	fake.setTempoMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.currentOctaveReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *Executor) CurrentOctaveCallCount() int {
-	fake.currentOctaveMutex.RLock()
-	defer fake.currentOctaveMutex.RUnlock()
-	return len(fake.currentOctaveArgsForCall)
+func (fake *Executor) SetTempoCallCount() int {
+	fake.setTempoMutex.RLock()
+	defer fake.setTempoMutex.RUnlock()
+	return len(fake.setTempoArgsForCall)
 }
 
-func (fake *Executor) CurrentOctaveReturns(result1 int) {
-	fake.CurrentOctaveStub = nil
-	fake.currentOctaveReturns = struct {
-		result1 int
+func (fake *Executor) SetTempoCalls(stub func(int) error) {
+	fake.setTempoMutex.Lock()
+	defer fake.setTempoMutex.Unlock()
+	fake.SetTempoStub = stub
+}
+
+func (fake *Executor) SetTempoArgsForCall(i int) int {
+	fake.setTempoMutex.RLock()
+	defer fake.setTempoMutex.RUnlock()
+	argsForCall := fake.setTempoArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Executor) SetTempoReturns(result1 error) {
+	fake.setTempoMutex.Lock()
+	defer fake.setTempoMutex.Unlock()
+	fake.SetTempoStub = nil
+	fake.setTempoReturns = struct {
+		result1 error
 	}{result1}
 }
 
-func (fake *Executor) CurrentOctaveReturnsOnCall(i int, result1 int) {
-	fake.CurrentOctaveStub = nil
-	if fake.currentOctaveReturnsOnCall == nil {
-		fake.currentOctaveReturnsOnCall = make(map[int]struct {
-			result1 int
+func (fake *Executor) SetTempoReturnsOnCall(i int, result1 error) {
+	fake.setTempoMutex.Lock()
+	defer fake.setTempoMutex.Unlock()
+	fake.SetTempoStub = nil
+	if fake.setTempoReturnsOnCall == nil {
+		fake.setTempoReturnsOnCall = make(map[int]struct {
+			result1 error
 		})
 	}
-	fake.currentOctaveReturnsOnCall[i] = struct {
-		result1 int
+	fake.setTempoReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) TieNote(arg1 string, arg2 string, arg3 int, arg4 bool) error {
+	fake.tieNoteMutex.Lock()
+	ret, specificReturn := fake.tieNoteReturnsOnCall[len(fake.tieNoteArgsForCall)]
+	fake.tieNoteArgsForCall = append(fake.tieNoteArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 int
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.TieNoteStub
+	fakeReturns := fake.tieNoteReturns
+	fake.recordInvocation("TieNote", []interface{}{arg1, arg2, arg3, arg4})
+	fake.tieNoteMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Executor) TieNoteCallCount() int {
+	fake.tieNoteMutex.RLock()
+	defer fake.tieNoteMutex.RUnlock()
+	return len(fake.tieNoteArgsForCall)
+}
+
+func (fake *Executor) TieNoteCalls(stub func(string, string, int, bool) error) {
+	fake.tieNoteMutex.Lock()
+	defer fake.tieNoteMutex.Unlock()
+	fake.TieNoteStub = stub
+}
+
+func (fake *Executor) TieNoteArgsForCall(i int) (string, string, int, bool) {
+	fake.tieNoteMutex.RLock()
+	defer fake.tieNoteMutex.RUnlock()
+	argsForCall := fake.tieNoteArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *Executor) TieNoteReturns(result1 error) {
+	fake.tieNoteMutex.Lock()
+	defer fake.tieNoteMutex.Unlock()
+	fake.TieNoteStub = nil
+	fake.tieNoteReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Executor) TieNoteReturnsOnCall(i int, result1 error) {
+	fake.tieNoteMutex.Lock()
+	defer fake.tieNoteMutex.Unlock()
+	fake.TieNoteStub = nil
+	if fake.tieNoteReturnsOnCall == nil {
+		fake.tieNoteReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.tieNoteReturnsOnCall[i] = struct {
+		result1 error
 	}{result1}
 }
 
 func (fake *Executor) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.addMarkerMutex.RLock()
+	defer fake.addMarkerMutex.RUnlock()
+	fake.currentOctaveMutex.RLock()
+	defer fake.currentOctaveMutex.RUnlock()
 	fake.emitNoteMutex.RLock()
 	defer fake.emitNoteMutex.RUnlock()
+	fake.emitNoteNumberMutex.RLock()
+	defer fake.emitNoteNumberMutex.RUnlock()
 	fake.emitRestMutex.RLock()
 	defer fake.emitRestMutex.RUnlock()
-	fake.setTempoMutex.RLock()
-	defer fake.setTempoMutex.RUnlock()
 	fake.setDefaultLengthMutex.RLock()
 	defer fake.setDefaultLengthMutex.RUnlock()
+	fake.setInstrumentMutex.RLock()
+	defer fake.setInstrumentMutex.RUnlock()
+	fake.setKeyMutex.RLock()
+	defer fake.setKeyMutex.RUnlock()
 	fake.setOctaveMutex.RLock()
 	defer fake.setOctaveMutex.RUnlock()
-	fake.currentOctaveMutex.RLock()
-	defer fake.currentOctaveMutex.RUnlock()
+	fake.setTempoMutex.RLock()
+	defer fake.setTempoMutex.RUnlock()
+	fake.tieNoteMutex.RLock()
+	defer fake.tieNoteMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
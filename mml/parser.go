@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 // AST is the root element of the abstract syntax tree generated by the
@@ -11,6 +12,10 @@ import (
 type AST struct {
 	Sequence  []Command
 	Positions []Position
+	// Spans are the parts of the input that each command was parsed from
+	Spans []Span
+	// Comments are the comments of the input, in order
+	Comments []Comment
 }
 
 // Command defines the commands that can be executed within a sheet of music.
@@ -30,24 +35,55 @@ func (p Position) String() string {
 	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
 }
 
+// Span identifies the part of the input string that a command was parsed
+// from, including its modifiers, numbers, and dots. End is the position just
+// after the last character of the command, and the offsets are the byte
+// offsets of the start and the end of the span.
+type Span struct {
+	Start       Position
+	End         Position
+	StartOffset int
+	EndOffset   int
+}
+
+// Comment is a comment like /* bar 12 */ and the part of the input that it
+// was scanned from. Text is everything between the /* and the */.
+type Comment struct {
+	Text string
+	Span Span
+}
+
 // Parser represents a parser.
 type Parser struct {
 	s *Scanner
 	// Saved token
 	tok Token
+	// Last token that was part of a command
+	prev    Token
+	started bool
 }
 
 // NewParser returns a new instance of Parser.
 func NewParser(r io.Reader) *Parser {
+	return NewDialectParser(r, Performgen)
+}
+
+// NewDialectParser returns a new instance of Parser for the given dialect.
+func NewDialectParser(r io.Reader, d *Dialect) *Parser {
 	return &Parser{
-		s: NewScanner(r),
+		s: NewDialectScanner(r, d),
 	}
 }
 
 func (p *Parser) scan() error {
+	p.prev = p.tok
 	p.tok = p.s.Scan()
 	if p.tok.Type() == TIllegal {
-		return fmt.Errorf("invalid token '%s' at %s", p.tok.Ident(), p.tok.Position())
+		return &ParseError{
+			Position: p.tok.Position(),
+			Token:    p.tok.Ident(),
+			Message:  fmt.Sprintf("invalid token '%s'", p.tok.Ident()),
+		}
 	}
 	return nil
 }
@@ -56,11 +92,58 @@ func (p *Parser) scan() error {
 // recursive descent parser (though the language is simple enough that there
 // is not currently any recursion involved).
 func (p *Parser) Parse() (*AST, error) {
-	err := p.scan()
+	err := p.start()
 	if err != nil {
 		return nil, err
 	}
-	return p.parseSequence()
+	ast := &AST{}
+	for {
+		cmd, span, err := p.Next()
+		ast.Comments = p.Comments()
+		if err != nil {
+			return ast, err
+		}
+		if cmd == nil {
+			break
+		}
+		ast.Sequence = append(ast.Sequence, cmd)
+		ast.Positions = append(ast.Positions, span.Start)
+		ast.Spans = append(ast.Spans, span)
+	}
+	return ast, nil
+}
+
+// Comments returns the comments of the input that have been read so far. The
+// parser reads ahead by one token, so this can include a comment after the
+// last command returned by Next.
+func (p *Parser) Comments() []Comment {
+	return p.s.Comments()
+}
+
+// Next parses and returns the next command of the input program along with
+// its span. It only reads as much of the input as is needed to parse the
+// command, so a long program can be executed while it's still being read.
+// At the end of the input, the returned command is nil.
+func (p *Parser) Next() (Command, Span, error) {
+	if err := p.start(); err != nil {
+		return nil, Span{}, err
+	}
+	span := p.tok.Span()
+	cmd, err := p.parseCommand()
+	if cmd != nil {
+		last := p.prev.Span()
+		span.End, span.EndOffset = last.End, last.EndOffset
+	}
+	return cmd, span, err
+}
+
+// start scans the first token of the input if it hasn't been scanned yet
+func (p *Parser) start() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	return p.scan()
 }
 
 // parseToken returns true if the next token is the expected type and advances
@@ -78,7 +161,11 @@ func (p *Parser) parseNumeric() (bool, int, error) {
 	if found {
 		n, parseErr := strconv.ParseInt(tok.Ident(), 10, 64)
 		if parseErr != nil {
-			return true, -1, parseErr
+			return true, -1, &ParseError{
+				Position: tok.Position(),
+				Token:    tok.Ident(),
+				Message:  fmt.Sprintf("invalid numeric argument '%s': value out of range", tok.Ident()),
+			}
 		}
 		return found, int(n), err
 	}
@@ -112,6 +199,16 @@ func (p *Parser) parseNoteCommand(cmdTok Token) (*NoteCommand, error) {
 	return &NoteCommand{Note: cmdTok.Ident(), Modifier: modifier, Length: numeric, Dot: dot}, nil
 }
 
+func (p *Parser) parseNoteNumberCommand(cmdTok Token) (*NoteNumberCommand, error) {
+	if found, number, err := p.parseNumeric(); found {
+		if err != nil {
+			return nil, err
+		}
+		return &NoteNumberCommand{Number: number}, nil
+	}
+	return nil, newCommandError(cmdTok, "Note number", "expected numeric argument")
+}
+
 func (p *Parser) parseRestCommand(cmdTok Token) (*RestCommand, error) {
 	var (
 		numeric = -1
@@ -139,7 +236,7 @@ func (p *Parser) parseTempoCommand(cmdTok Token) (*TempoCommand, error) {
 		}
 		return &TempoCommand{Tempo: tempo}, nil
 	}
-	return nil, fmt.Errorf("Tempo command at %s: expected numeric argument", cmdTok.Position())
+	return nil, newCommandError(cmdTok, "Tempo", "expected numeric argument")
 }
 
 func (p *Parser) parseLengthCommand(cmdTok Token) (*LengthCommand, error) {
@@ -160,7 +257,7 @@ func (p *Parser) parseLengthCommand(cmdTok Token) (*LengthCommand, error) {
 		dot = true
 	}
 	if length == -1 {
-		return nil, fmt.Errorf("Length command at %s: expected numeric argument", cmdTok.Position())
+		return nil, newCommandError(cmdTok, "Length", "expected numeric argument")
 	}
 	return &LengthCommand{Length: length, Dot: dot}, nil
 }
@@ -171,7 +268,32 @@ func (p *Parser) parseOctaveCommand(cmdTok Token) (*OctaveCommand, error) {
 		}
 		return &OctaveCommand{Octave: octave}, nil
 	}
-	return nil, fmt.Errorf("Octave command at %s: expected numeric argument", cmdTok.Position())
+	return nil, newCommandError(cmdTok, "Octave", "expected numeric argument")
+}
+
+func (p *Parser) parseKeyCommand(cmdTok Token) (*KeyCommand, error) {
+	if cmdTok.Ident() == "" {
+		return nil, newCommandError(cmdTok, "Key", "expected key name")
+	}
+	return &KeyCommand{Key: cmdTok.Ident()}, nil
+}
+
+func (p *Parser) parseMarkerCommand(cmdTok Token) (*MarkerCommand, error) {
+	name := strings.TrimPrefix(cmdTok.Ident(), "*")
+	if name == "" {
+		return nil, newCommandError(cmdTok, "Marker", "expected marker name")
+	}
+	return &MarkerCommand{Name: name}, nil
+}
+
+func (p *Parser) parseInstrumentCommand(cmdTok Token) (*InstrumentCommand, error) {
+	if found, instrument, err := p.parseNumeric(); found {
+		if err != nil {
+			return nil, err
+		}
+		return &InstrumentCommand{Instrument: instrument}, nil
+	}
+	return nil, newCommandError(cmdTok, "Instrument", "expected numeric argument")
 }
 
 func (p *Parser) parseOctaveUpCommand(cmdTok Token) (*OctaveUpCommand, error) {
@@ -182,17 +304,17 @@ func (p *Parser) parseOctaveDownCommand(cmdTok Token) (*OctaveDownCommand, error
 	return &OctaveDownCommand{}, nil
 }
 
-func (p *Parser) parseVolumeCommand(cmdTok Token) (*NoOpCommand, error) {
-	if found, _, err := p.parseNumeric(); found {
+func (p *Parser) parseVolumeCommand(cmdTok Token) (*VolumeCommand, error) {
+	if found, volume, err := p.parseNumeric(); found {
 		if err != nil {
 			return nil, err
 		}
-		return &NoOpCommand{}, nil
+		return &VolumeCommand{Volume: volume}, nil
 	}
-	return nil, fmt.Errorf("Volume command at %s: expected numeric argument", cmdTok.Position())
+	return nil, newCommandError(cmdTok, "Volume", "expected numeric argument")
 }
 
-func (p *Parser) parseExtendCommand(cmdTok Token) (*RestCommand, error) {
+func (p *Parser) parseExtendCommand(cmdTok Token) (Command, error) {
 	if found, tok, err := p.parseToken(TNote); found {
 		if err != nil {
 			return nil, err
@@ -201,6 +323,9 @@ func (p *Parser) parseExtendCommand(cmdTok Token) (*RestCommand, error) {
 		if err != nil {
 			return nil, err
 		}
+		if p.s.dialect.TieNotes {
+			return &TieCommand{Note: cmd.Note, Modifier: cmd.Modifier, Length: cmd.Length, Dot: cmd.Dot}, nil
+		}
 		return &RestCommand{Length: cmd.Length}, nil
 	} else if found, tok, err := p.parseToken(TRest); found {
 		if err != nil {
@@ -208,7 +333,7 @@ func (p *Parser) parseExtendCommand(cmdTok Token) (*RestCommand, error) {
 		}
 		return p.parseRestCommand(tok)
 	}
-	return nil, fmt.Errorf("Extend command at %s: expected note or rest command", cmdTok.Position())
+	return nil, newCommandError(cmdTok, "Extend", "expected note or rest command")
 }
 
 func (p *Parser) parseCommand() (Command, error) {
@@ -220,6 +345,8 @@ func (p *Parser) parseCommand() (Command, error) {
 	switch cmdTok.Type() {
 	case TNote:
 		return p.parseNoteCommand(cmdTok)
+	case TNoteNumber:
+		return p.parseNoteNumberCommand(cmdTok)
 	case TRest:
 		return p.parseRestCommand(cmdTok)
 	case TTempo:
@@ -228,6 +355,12 @@ func (p *Parser) parseCommand() (Command, error) {
 		return p.parseLengthCommand(cmdTok)
 	case TOctave:
 		return p.parseOctaveCommand(cmdTok)
+	case TKey:
+		return p.parseKeyCommand(cmdTok)
+	case TInstrument:
+		return p.parseInstrumentCommand(cmdTok)
+	case TMarker:
+		return p.parseMarkerCommand(cmdTok)
 	case TOctaveUp:
 		return p.parseOctaveUpCommand(cmdTok)
 	case TOctaveDown:
@@ -236,25 +369,26 @@ func (p *Parser) parseCommand() (Command, error) {
 		return p.parseVolumeCommand(cmdTok)
 	case TExtend:
 		return p.parseExtendCommand(cmdTok)
+	case TTie:
+		return p.parseRestCommand(cmdTok)
 	case TEOF:
 		return nil, nil
 	default:
-		return nil, fmt.Errorf("expected command, got '%s' at %s", cmdTok.Ident(), cmdTok.Position())
+		return nil, &ParseError{
+			Position: cmdTok.Position(),
+			Token:    cmdTok.Ident(),
+			Message:  fmt.Sprintf("expected command, got '%s'", cmdTok.Ident()),
+		}
 	}
 }
-func (p *Parser) parseSequence() (*AST, error) {
-	ast := &AST{}
-	for {
-		pos := p.tok.Position()
-		cmd, err := p.parseCommand()
-		if err != nil {
-			return ast, err
-		}
-		if cmd == nil {
-			break
-		}
-		ast.Sequence = append(ast.Sequence, cmd)
-		ast.Positions = append(ast.Positions, pos)
+
+// newCommandError returns the error for a command that is missing its
+// argument
+func newCommandError(cmdTok Token, command, message string) *ParseError {
+	return &ParseError{
+		Position: cmdTok.Position(),
+		Token:    cmdTok.Ident(),
+		Command:  command,
+		Message:  message,
 	}
-	return ast, nil
 }
@@ -39,7 +39,7 @@ var _ = Describe("Parser", func() {
 				&mml.NoteCommand{Note: "E", Length: 5, Dot: true},
 				&mml.RestCommand{Length: 5, Dot: true},
 				&mml.LengthCommand{Length: 4, Dot: true},
-				&mml.NoOpCommand{},
+				&mml.VolumeCommand{Volume: 127},
 			}))
 			Expect(ast.Positions).To(Equal([]mml.Position{
 				{Line: 1, Column: 1},
@@ -64,6 +64,75 @@ var _ = Describe("Parser", func() {
 			}))
 		})
 	})
+	Describe("Next", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("T120 a\n  b8"))
+		})
+		It("returns one command at a time until the end of the input", func() {
+			parser := mml.NewParser(input)
+			cmd, span, err := parser.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmd).To(Equal(&mml.TempoCommand{Tempo: 120}))
+			Expect(span).To(Equal(mml.Span{
+				Start: mml.Position{Line: 1, Column: 1}, End: mml.Position{Line: 1, Column: 5},
+				StartOffset: 0, EndOffset: 4,
+			}))
+
+			cmd, span, err = parser.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmd).To(Equal(&mml.NoteCommand{Note: "a", Length: -1}))
+			Expect(span).To(Equal(mml.Span{
+				Start: mml.Position{Line: 1, Column: 6}, End: mml.Position{Line: 1, Column: 7},
+				StartOffset: 5, EndOffset: 6,
+			}))
+
+			cmd, span, err = parser.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmd).To(Equal(&mml.NoteCommand{Note: "b", Length: 8}))
+			Expect(span).To(Equal(mml.Span{
+				Start: mml.Position{Line: 2, Column: 3}, End: mml.Position{Line: 2, Column: 5},
+				StartOffset: 9, EndOffset: 11,
+			}))
+
+			cmd, _, err = parser.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmd).To(BeNil())
+		})
+	})
+	Describe("Spans", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("c+4. r8\n&d16 l8"))
+		})
+		It("covers the modifiers, numbers, and dots of every command", func() {
+			parser := mml.NewParser(input)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Spans).To(Equal([]mml.Span{
+				{Start: mml.Position{Line: 1, Column: 1}, End: mml.Position{Line: 1, Column: 5}, StartOffset: 0, EndOffset: 4},
+				{Start: mml.Position{Line: 1, Column: 6}, End: mml.Position{Line: 1, Column: 8}, StartOffset: 5, EndOffset: 7},
+				{Start: mml.Position{Line: 2, Column: 1}, End: mml.Position{Line: 2, Column: 5}, StartOffset: 8, EndOffset: 12},
+				{Start: mml.Position{Line: 2, Column: 6}, End: mml.Position{Line: 2, Column: 8}, StartOffset: 13, EndOffset: 15},
+			}))
+			for i, span := range ast.Spans {
+				Expect(span.Start).To(Equal(ast.Positions[i]))
+			}
+		})
+	})
+	Describe("Comments", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("/* a */ c\nd /* b\n*c */"))
+		})
+		It("keeps the text and span of every comment", func() {
+			parser := mml.NewParser(input)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Sequence).To(HaveLen(2))
+			Expect(ast.Comments).To(Equal([]mml.Comment{
+				{Text: " a ", Span: mml.Span{Start: mml.Position{Line: 1, Column: 1}, End: mml.Position{Line: 1, Column: 8}, StartOffset: 0, EndOffset: 7}},
+				{Text: " b\n*c ", Span: mml.Span{Start: mml.Position{Line: 2, Column: 3}, End: mml.Position{Line: 3, Column: 6}, StartOffset: 12, EndOffset: 22}},
+			}))
+		})
+	})
 	Describe("Extend Command", func() {
 		Context("with a note argument", func() {
 			BeforeEach(func() {
@@ -97,6 +166,21 @@ var _ = Describe("Parser", func() {
 				}))
 			})
 		})
+		Context("with a dialect that ties notes", func() {
+			BeforeEach(func() {
+				input = bytes.NewReader([]byte("    c4&c+8. &r16"))
+			})
+			It("translates notes to a TieCommand", func() {
+				parser := mml.NewDialectParser(input, mml.Mabinogi)
+				ast, err := parser.Parse()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ast.Sequence).To(Equal([]mml.Command{
+					&mml.NoteCommand{Note: "c", Length: 4},
+					&mml.TieCommand{Note: "c", Modifier: "+", Length: 8, Dot: true},
+					&mml.RestCommand{Length: 16},
+				}))
+			})
+		})
 		Context("with an invalid argument", func() {
 			BeforeEach(func() {
 				input = bytes.NewReader([]byte("    &v16"))
@@ -108,6 +192,124 @@ var _ = Describe("Parser", func() {
 			})
 		})
 	})
+	Describe("Note Number Command", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("    n60 N48"))
+		})
+		It("generates NoteNumberCommands", func() {
+			parser := mml.NewParser(input)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Sequence).To(Equal([]mml.Command{
+				&mml.NoteNumberCommand{Number: 60},
+				&mml.NoteNumberCommand{Number: 48},
+			}))
+			Expect(ast.Positions).To(Equal([]mml.Position{
+				{Line: 1, Column: 5},
+				{Line: 1, Column: 9},
+			}))
+		})
+	})
+	Describe("Tie Command", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("    c4^8.^"))
+		})
+		It("translates to a RestCommand", func() {
+			parser := mml.NewDialectParser(input, mml.ArcheAge)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Sequence).To(Equal([]mml.Command{
+				&mml.NoteCommand{Note: "c", Length: 4},
+				&mml.RestCommand{Length: 8, Dot: true},
+				&mml.RestCommand{Length: -1},
+			}))
+		})
+		It("is not recognized by dialects without caret ties", func() {
+			parser := mml.NewParser(input)
+			_, err := parser.Parse()
+			Expect(err).To(MatchError("invalid token '^' at line 1, column 7"))
+		})
+	})
+	Describe("Instrument Command", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("    @5 c @23"))
+		})
+		It("generates InstrumentCommands", func() {
+			parser := mml.NewParser(input)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Sequence).To(Equal([]mml.Command{
+				&mml.InstrumentCommand{Instrument: 5},
+				&mml.NoteCommand{Note: "c", Length: -1},
+				&mml.InstrumentCommand{Instrument: 23},
+			}))
+		})
+		It("is not recognized by dialects without instruments", func() {
+			parser := mml.NewDialectParser(input, mml.Mabinogi)
+			_, err := parser.Parse()
+			Expect(err).To(MatchError("invalid token '@' at line 1, column 5"))
+		})
+	})
+	Describe("Marker Command", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("*intro c *Chorus2 d"))
+		})
+		It("generates MarkerCommands", func() {
+			parser := mml.NewParser(input)
+			ast, err := parser.Parse()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ast.Sequence).To(Equal([]mml.Command{
+				&mml.MarkerCommand{Name: "intro"},
+				&mml.NoteCommand{Note: "c", Length: -1},
+				&mml.MarkerCommand{Name: "Chorus2"},
+				&mml.NoteCommand{Note: "d", Length: -1},
+			}))
+			Expect(ast.Spans[2].Start).To(Equal(mml.Position{Line: 1, Column: 10}))
+			Expect(ast.Spans[2].End).To(Equal(mml.Position{Line: 1, Column: 18}))
+		})
+		It("errors if the marker has no name", func() {
+			parser := mml.NewParser(bytes.NewReader([]byte("c * d")))
+			_, err := parser.Parse()
+			Expect(err).To(MatchError("Marker command at line 1, column 3: expected marker name"))
+		})
+		It("is not recognized by dialects without markers", func() {
+			parser := mml.NewDialectParser(input, mml.ThreeMLE)
+			_, err := parser.Parse()
+			Expect(err).To(MatchError("invalid token '*' at line 1, column 1"))
+		})
+	})
+	Describe("Key Command", func() {
+		Context("with a key name", func() {
+			BeforeEach(func() {
+				input = bytes.NewReader([]byte("    kB- b b=4"))
+			})
+			It("generates a KeyCommand followed by notes", func() {
+				parser := mml.NewParser(input)
+				ast, err := parser.Parse()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ast.Sequence).To(Equal([]mml.Command{
+					&mml.KeyCommand{Key: "B-"},
+					&mml.NoteCommand{Note: "b", Length: -1},
+					&mml.NoteCommand{Note: "b", Modifier: "=", Length: 4},
+				}))
+				Expect(ast.Positions).To(Equal([]mml.Position{
+					{Line: 1, Column: 5},
+					{Line: 1, Column: 9},
+					{Line: 1, Column: 11},
+				}))
+			})
+		})
+		Context("without a key name", func() {
+			BeforeEach(func() {
+				input = bytes.NewReader([]byte("    k 4"))
+			})
+			It("errors", func() {
+				parser := mml.NewParser(input)
+				_, err := parser.Parse()
+				Expect(err).To(MatchError("Key command at line 1, column 5: expected key name"))
+			})
+		})
+	})
 	DescribeTable("commands with required numeric arguments should error when not provided a numeric argument",
 		func(command, input string) {
 			reader := bytes.NewReader([]byte(input))
@@ -115,10 +317,12 @@ var _ = Describe("Parser", func() {
 			_, err := parser.Parse()
 			Expect(err).To(MatchError(command + " command at line 1, column 5: expected numeric argument"))
 		},
+		Entry("Note number Command", "Note number", "    N a"),
 		Entry("Tempo Command", "Tempo", "    T a"),
 		Entry("Length Command", "Length", "    L a"),
 		Entry("Octave Command", "Octave", "    O a"),
 		Entry("Volume Command", "Volume", "    V a"),
+		Entry("Instrument Command", "Instrument", "    @ a"),
 	)
 	DescribeTable("unrecognized tokens in various places should error",
 		func(input string, location mml.Position) {
@@ -158,6 +362,7 @@ var _ = Describe("Parser", func() {
 			Expect(err).To(MatchError(ContainSubstring("value out of range")))
 		},
 		Entry("Note", "    A9223372036854775808"),
+		Entry("Note number", "    N9223372036854775808"),
 		Entry("Rest", "    R9223372036854775808"),
 		Entry("Tempo", "    T9223372036854775808"),
 		Entry("Length", "    L9223372036854775808"),
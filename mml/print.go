@@ -0,0 +1,173 @@
+package mml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PrintOptions configures Print
+type PrintOptions struct {
+	// Dialect is used to execute the commands to find where each measure ends.
+	// If it is nil, the Performgen dialect is used.
+	Dialect *Dialect
+	// BeatsPerMeasure is the number of quarter note beats in a measure. If it
+	// is 0, there are 4 beats in a measure.
+	BeatsPerMeasure int
+	// BarNumbers adds a comment with the number of the measure to the start of
+	// each line
+	BarNumbers bool
+}
+
+// beatEpsilon absorbs the rounding errors of lengths like triplets when
+// finding the measure of a beat
+const beatEpsilon = 1e-9
+
+// Print writes the commands of the syntax tree as MML in a consistent style.
+// Commands are written in lowercase, and a new line is started after the
+// command that reaches the end of a measure. The printed MML plays exactly
+// the same as the original, and printing it again doesn't change it.
+// Commands that fail to execute don't take up any time when finding the end
+// of a measure.
+//
+// Comments are written before the command that follows them, or after the
+// command that they share a line with. Comments are placed using the Spans of
+// the syntax tree, so any comments left over are written at the end. With
+// BarNumbers, comments with a bar number are replaced with new ones.
+func Print(w io.Writer, ast *AST, opts PrintOptions) error {
+	measureBeats := 4.0
+	if opts.BeatsPerMeasure > 0 {
+		measureBeats = float64(opts.BeatsPerMeasure)
+	}
+	measureAt := func(beats float64) int {
+		return int(math.Floor(beats/measureBeats + beatEpsilon))
+	}
+
+	bw := bufio.NewWriter(w)
+	state := &State{Dialect: opts.Dialect}
+	var (
+		lineMeasure = -1
+		prev        Command
+		comments    = ast.Comments
+	)
+	// write writes the text of a command, or of a comment if cmd is nil
+	write := func(text string, cmd Command) {
+		if lineMeasure == -1 {
+			lineMeasure = measureAt(state.Beats)
+			if opts.BarNumbers {
+				fmt.Fprintf(bw, "/* bar %d */ ", lineMeasure+1)
+			}
+		} else if !compact(prev) || !compact(cmd) {
+			_ = bw.WriteByte(' ')
+		}
+		_, _ = bw.WriteString(text)
+		prev = cmd
+	}
+	// writeComments writes the comments that come before the offset and start
+	// on the line, or on any line if line is 0
+	writeComments := func(offset, line int) {
+		for len(comments) > 0 && comments[0].Span.StartOffset < offset &&
+			(line == 0 || comments[0].Span.Start.Line == line) {
+			if !opts.BarNumbers || !isBarNumber(comments[0].Text) {
+				write("/*"+comments[0].Text+"*/", nil)
+			}
+			comments = comments[1:]
+		}
+	}
+	for i, cmd := range ast.Sequence {
+		if _, ok := cmd.(*NoOpCommand); ok {
+			continue
+		}
+		text, err := printCommand(cmd)
+		if err != nil {
+			return err
+		}
+		if i < len(ast.Spans) {
+			writeComments(ast.Spans[i].StartOffset, 0)
+		}
+		write(text, cmd)
+
+		_ = cmd.Execute(state)
+		if i < len(ast.Spans) {
+			next := math.MaxInt32
+			if i+1 < len(ast.Spans) {
+				next = ast.Spans[i+1].StartOffset
+			}
+			writeComments(next, ast.Spans[i].End.Line)
+		}
+		if measureAt(state.Beats) > lineMeasure {
+			_ = bw.WriteByte('\n')
+			lineMeasure = -1
+		}
+	}
+	writeComments(math.MaxInt32, 0)
+	if lineMeasure != -1 {
+		_ = bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// isBarNumber returns true if the text of a comment is a bar number written
+// by Print
+func isBarNumber(text string) bool {
+	var n int
+	_, err := fmt.Sscanf(text, " bar %d ", &n)
+	return err == nil && text == fmt.Sprintf(" bar %d ", n)
+}
+
+// compact returns true for the commands that are written next to each other
+// without a space, like the notes of a phrase
+func compact(cmd Command) bool {
+	switch cmd.(type) {
+	case *NoteCommand, *RestCommand, *TieCommand, *OctaveUpCommand, *OctaveDownCommand:
+		return true
+	}
+	return false
+}
+
+// printCommand returns the MML of a single command
+func printCommand(cmd Command) (string, error) {
+	switch c := cmd.(type) {
+	case *NoteCommand:
+		return strings.ToLower(c.Note) + c.Modifier + printLength(c.Length, c.Dot), nil
+	case *TieCommand:
+		return "&" + strings.ToLower(c.Note) + c.Modifier + printLength(c.Length, c.Dot), nil
+	case *NoteNumberCommand:
+		return "n" + strconv.Itoa(c.Number), nil
+	case *RestCommand:
+		return "r" + printLength(c.Length, c.Dot), nil
+	case *TempoCommand:
+		return "t" + strconv.Itoa(c.Tempo), nil
+	case *LengthCommand:
+		return "l" + printLength(c.Length, c.Dot), nil
+	case *OctaveCommand:
+		return "o" + strconv.Itoa(c.Octave), nil
+	case *OctaveUpCommand:
+		return ">", nil
+	case *OctaveDownCommand:
+		return "<", nil
+	case *KeyCommand:
+		return "k" + strings.ToLower(c.Key), nil
+	case *VolumeCommand:
+		return "v" + strconv.Itoa(c.Volume), nil
+	case *InstrumentCommand:
+		return "@" + strconv.Itoa(c.Instrument), nil
+	case *MarkerCommand:
+		return "*" + c.Name, nil
+	}
+	return "", fmt.Errorf("cannot print command of type %T", cmd)
+}
+
+func printLength(length int, dot bool) string {
+	var s string
+	if length != -1 {
+		s = strconv.Itoa(length)
+	}
+	if dot {
+		s += "."
+	}
+	return s
+}
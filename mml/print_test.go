@@ -0,0 +1,106 @@
+package mml_test
+
+import (
+	"bytes"
+
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type unknownCommand struct{}
+
+func (u *unknownCommand) Execute(e mml.Executor) error { return nil }
+
+var _ = Describe("Print", func() {
+	var opts mml.PrintOptions
+	BeforeEach(func() {
+		opts = mml.PrintOptions{}
+	})
+	print := func(input string) string {
+		ast, err := mml.NewDialectParser(bytes.NewReader([]byte(input)), mml.Performgen).Parse()
+		Expect(err).ToNot(HaveOccurred())
+		buf := new(bytes.Buffer)
+		Expect(mml.Print(buf, ast, opts)).To(Succeed())
+		return buf.String()
+	}
+
+	It("writes lowercase commands and starts a new line after each measure", func() {
+		Expect(print("T120 L8 O4 CDEFGAB>C<BAG")).To(Equal("t120 l8 o4 cdefgab>c\n<bag\n"))
+	})
+	It("writes every kind of command", func() {
+		Expect(print("V100 KF#m @5 L16. C+8.D-0R. &E4 N60 O5 >A<B r")).To(Equal(
+			"v100 kf#m @5 l16. c+8.d-0r.r4 n60 o5 >a<br\n",
+		))
+	})
+	It("separates markers from the following commands", func() {
+		Expect(print("*intro L8 CD *chorus EF")).To(Equal("*intro l8 cd *chorus ef\n"))
+	})
+	It("starts a new line after a note that continues into the next measure", func() {
+		Expect(print("c2. c2 c")).To(Equal("c2.c2\nc\n"))
+	})
+	It("doesn't count notes of length 0 as part of the measure", func() {
+		Expect(print("c0 d1 e0 f")).To(Equal("c0d1\ne0f\n"))
+	})
+	It("keeps comments before the next command or after the command on the same line", func() {
+		Expect(print("/* intro */ t120 l8 cdef /* rising */\ngab>c /*high*/ c1\n/* end */")).To(Equal(
+			"/* intro */ t120 l8 cdef /* rising */ gab>c /*high*/\nc1\n/* end */\n",
+		))
+	})
+	It("keeps comments that span lines", func() {
+		once := print("c1 /* first\nsecond */ d1")
+		Expect(once).To(Equal("c1 /* first\nsecond */\nd1\n"))
+		Expect(print(once)).To(Equal(once))
+	})
+	It("writes the comments at the end if the commands have no spans", func() {
+		ast, err := mml.NewParser(bytes.NewReader([]byte("/* a */ c d /* b */"))).Parse()
+		Expect(err).ToNot(HaveOccurred())
+		ast.Spans = nil
+		buf := new(bytes.Buffer)
+		Expect(mml.Print(buf, ast, opts)).To(Succeed())
+		Expect(buf.String()).To(Equal("cd /* a */ /* b */\n"))
+	})
+	It("is idempotent", func() {
+		once := print("t150 l8 o4 c d e f g a b >c < b a g f e d c l4. c c c /* comment */ c")
+		Expect(print(once)).To(Equal(once))
+	})
+
+	Context("with a different number of beats per measure", func() {
+		BeforeEach(func() {
+			opts.BeatsPerMeasure = 3
+		})
+		It("starts a new line after each measure", func() {
+			Expect(print("l4 c d e f g a b")).To(Equal("l4 cde\nfga\nb\n"))
+		})
+	})
+
+	Context("with bar numbers", func() {
+		BeforeEach(func() {
+			opts.BarNumbers = true
+		})
+		It("adds the number of the measure to the start of each line", func() {
+			Expect(print("t120 l8 o4 cdefgab>c<bag")).To(Equal(
+				"/* bar 1 */ t120 l8 o4 cdefgab>c\n/* bar 2 */ <bag\n",
+			))
+		})
+		It("numbers each line by the measure that it starts in", func() {
+			Expect(print("c1 c1 c1")).To(Equal("/* bar 1 */ c1\n/* bar 2 */ c1\n/* bar 3 */ c1\n"))
+			Expect(print("c c c c2 c1 c")).To(Equal("/* bar 1 */ cccc2\n/* bar 2 */ c1\n/* bar 3 */ c\n"))
+		})
+		It("replaces the bar numbers that are already there and keeps other comments", func() {
+			Expect(print("/* bar 7 */ c1 /* bar */\n/* bar 1 */ /* bar 2x */ c1")).To(Equal(
+				"/* bar 1 */ c1 /* bar */\n/* bar 2 */ /* bar 2x */ c1\n",
+			))
+		})
+		It("is idempotent", func() {
+			once := print("t120 l8 o4 cdefgab>c<bag /* comment */ c")
+			Expect(print(once)).To(Equal(once))
+		})
+	})
+
+	It("errors if a command can't be printed", func() {
+		ast := &mml.AST{Sequence: []mml.Command{&unknownCommand{}}}
+		Expect(mml.Print(new(bytes.Buffer), ast, opts)).To(MatchError("cannot print command of type *mml_test.unknownCommand"))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"unicode/utf8"
 )
 
 // TokenType is defined to specifically talk about token types rather than ints
@@ -12,6 +13,7 @@ type TokenType int
 // These constants define the different possible token types
 const (
 	TNote TokenType = iota
+	TNoteNumber
 	TRest
 	TTempo
 	TLength
@@ -20,8 +22,12 @@ const (
 	TOctaveDown
 	TVolume
 	TExtend
+	TTie
 	TDot
 	TModifier
+	TKey
+	TInstrument
+	TMarker
 	TNumeric
 	TEOF
 	TIllegal
@@ -29,9 +35,10 @@ const (
 
 // Token defines the type for a lexical token
 type Token struct {
-	typ   TokenType
-	ident string
-	pos   Position
+	typ    TokenType
+	ident  string
+	pos    Position
+	offset int
 }
 
 // Type returns the type of the token
@@ -49,10 +56,27 @@ func (t Token) Position() Position {
 	return t.pos
 }
 
+// Span returns the part of the input string that the token was scanned from
+func (t Token) Span() Span {
+	runes, size := utf8.RuneCountInString(t.ident), len(t.ident)
+	if t.typ == TKey {
+		// The identifier of a key is only the key name, without the k
+		runes, size = runes+1, size+1
+	}
+	return Span{
+		Start:       t.pos,
+		End:         Position{Line: t.pos.Line, Column: t.pos.Column + runes},
+		StartOffset: t.offset,
+		EndOffset:   t.offset + size,
+	}
+}
+
 func isWhitespace(ch rune) bool { return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' }
 
 func isNumeric(ch rune) bool { return (ch >= '0' && ch <= '9') }
 
+func isNoteLetter(ch rune) bool { return (ch >= 'a' && ch <= 'g') || (ch >= 'A' && ch <= 'G') }
+
 var eof = rune(0)
 
 // Scanner represents a lexical scanner.
@@ -62,11 +86,23 @@ type Scanner struct {
 	colNum     int
 	prevColNum int
 	reachedEOF bool
+	dialect    *Dialect
+	// offset is the number of bytes read so far, and lastSize is the size of
+	// the last rune read
+	offset   int
+	lastSize int
+	comments []Comment
 }
 
 // NewScanner returns a new instance of Scanner.
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r)}
+	return NewDialectScanner(r, Performgen)
+}
+
+// NewDialectScanner returns a new instance of Scanner that only recognizes
+// the commands of the given dialect.
+func NewDialectScanner(r io.Reader, d *Dialect) *Scanner {
+	return &Scanner{r: bufio.NewReader(r), dialect: d}
 }
 
 // read reads the next rune from the bufferred reader.
@@ -75,14 +111,17 @@ func (s *Scanner) read() rune {
 	if s.lineNum == 0 {
 		s.lineNum = 1
 	}
-	ch, _, err := s.r.ReadRune()
+	ch, size, err := s.r.ReadRune()
 	if err != nil {
+		s.lastSize = 0
 		if !s.reachedEOF {
 			s.colNum++
 			s.reachedEOF = true
 		}
 		return eof
 	}
+	s.offset += size
+	s.lastSize = size
 	s.colNum++
 	if ch == '\n' {
 		s.prevColNum = s.colNum
@@ -95,9 +134,11 @@ func (s *Scanner) read() rune {
 // unread places the previously read rune back on the reader.
 func (s *Scanner) unread() {
 	_ = s.r.UnreadRune()
+	s.offset -= s.lastSize
 	s.colNum--
 	if s.colNum < 0 {
 		s.lineNum--
+		s.colNum = s.prevColNum - 1
 	}
 }
 
@@ -106,9 +147,18 @@ func (s *Scanner) Scan() Token {
 	// Read the next rune.
 	ch := s.read()
 
-	// Eat all whitespace
-	if isWhitespace(ch) {
-		s.eatWhitespace()
+	// Eat all whitespace and comments
+	for {
+		if isWhitespace(ch) {
+			s.eatWhitespace()
+			ch = s.read()
+		}
+		if ch != '/' {
+			break
+		}
+		if tok, ok := s.eatComment(); !ok {
+			return tok
+		}
 		ch = s.read()
 	}
 
@@ -122,14 +172,30 @@ func (s *Scanner) Scan() Token {
 	switch ch {
 	case eof:
 		return s.buildToken(TEOF, string(ch))
+	case 'n', 'N':
+		if s.dialect.NoteNumbers {
+			return s.buildToken(TNoteNumber, string(ch))
+		}
 	case 'r', 'R':
 		return s.buildToken(TRest, string(ch))
 	case 't', 'T':
 		return s.buildToken(TTempo, string(ch))
 	case 'l', 'L':
 		return s.buildToken(TLength, string(ch))
-	case '#', '+', '-':
+	case '#', '+', '-', '=':
 		return s.buildToken(TModifier, string(ch))
+	case 'k', 'K':
+		if s.dialect.KeySignatures {
+			return s.scanKey()
+		}
+	case '@':
+		if s.dialect.Instruments {
+			return s.buildToken(TInstrument, string(ch))
+		}
+	case '*':
+		if s.dialect.Markers {
+			return s.scanMarker()
+		}
 	case 'o', 'O':
 		return s.buildToken(TOctave, string(ch))
 	case '>':
@@ -140,10 +206,14 @@ func (s *Scanner) Scan() Token {
 		return s.buildToken(TVolume, string(ch))
 	case '&':
 		return s.buildToken(TExtend, string(ch))
+	case '^':
+		if s.dialect.CaretTies {
+			return s.buildToken(TTie, string(ch))
+		}
 	case '.':
 		return s.buildToken(TDot, string(ch))
 	default:
-		if (ch >= 'a' && ch <= 'g') || (ch >= 'A' && ch <= 'G') {
+		if isNoteLetter(ch) {
 			return s.buildToken(TNote, string(ch))
 		}
 	}
@@ -153,9 +223,10 @@ func (s *Scanner) Scan() Token {
 
 func (s *Scanner) buildToken(typ TokenType, ch string) Token {
 	return Token{
-		typ:   typ,
-		ident: ch,
-		pos:   Position{s.lineNum, s.colNum},
+		typ:    typ,
+		ident:  ch,
+		pos:    Position{s.lineNum, s.colNum},
+		offset: s.offset - s.lastSize,
 	}
 }
 
@@ -175,6 +246,50 @@ func (s *Scanner) eatWhitespace() {
 	return
 }
 
+// eatComment consumes a comment like /* bar 12 */ after its first slash and
+// adds it to the comments of the scanner. If the slash doesn't start a comment
+// or the comment isn't closed, an illegal token is returned instead.
+func (s *Scanner) eatComment() (Token, bool) {
+	tok := s.buildToken(TIllegal, "/")
+	if ch := s.read(); ch != '*' {
+		if ch != eof {
+			s.unread()
+		}
+		return tok, false
+	}
+	var (
+		buf  bytes.Buffer
+		prev rune
+	)
+	for {
+		ch := s.read()
+		if ch == eof {
+			tok.ident = "/*"
+			return tok, false
+		} else if prev == '*' && ch == '/' {
+			text := buf.String()
+			s.comments = append(s.comments, Comment{
+				Text: text[:len(text)-1],
+				Span: Span{
+					Start:       tok.pos,
+					End:         Position{Line: s.lineNum, Column: s.colNum + 1},
+					StartOffset: tok.offset,
+					EndOffset:   s.offset,
+				},
+			})
+			return Token{}, true
+		}
+		_, _ = buf.WriteRune(ch)
+		prev = ch
+	}
+}
+
+// Comments returns the comments that have been scanned so far, in the order
+// that they appear in the input
+func (s *Scanner) Comments() []Comment {
+	return s.comments
+}
+
 // scanNumeric consumes the current rune and all contiguous numeric runes.
 func (s *Scanner) scanNumeric(lineNum, colNum int) Token {
 	// Create a buffer and read the current character into it.
@@ -200,3 +315,59 @@ func (s *Scanner) scanNumeric(lineNum, colNum int) Token {
 	// Otherwise return as a regular identifier.
 	return tok
 }
+
+// scanMarker consumes the name of a marker like *chorus after the asterisk.
+// The asterisk and the name are returned as the identifier of the token. The
+// name ends at the first rune that isn't a letter, a digit, or an underscore.
+func (s *Scanner) scanMarker() Token {
+	tok := s.buildToken(TMarker, "")
+
+	var buf bytes.Buffer
+	_, _ = buf.WriteRune('*')
+	for {
+		ch := s.read()
+		if ch == eof {
+			break
+		} else if !isMarkerName(ch) {
+			s.unread()
+			break
+		}
+		_, _ = buf.WriteRune(ch)
+	}
+
+	tok.ident = buf.String()
+	return tok
+}
+
+func isMarkerName(ch rune) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || isNumeric(ch) || ch == '_'
+}
+
+// scanKey consumes a key name like C, E-, F#m, or a-m after a key command.
+// The key name is returned as the identifier of the token, and is empty if
+// there is no key name after the key command.
+func (s *Scanner) scanKey() Token {
+	tok := s.buildToken(TKey, "")
+
+	var buf bytes.Buffer
+	ch := s.read()
+	if !isNoteLetter(ch) {
+		if ch != eof {
+			s.unread()
+		}
+		return tok
+	}
+	_, _ = buf.WriteRune(ch)
+	if ch = s.read(); ch == '#' || ch == '+' || ch == '-' {
+		_, _ = buf.WriteRune(ch)
+		ch = s.read()
+	}
+	if ch == 'm' || ch == 'M' {
+		_, _ = buf.WriteRune(ch)
+	} else if ch != eof {
+		s.unread()
+	}
+
+	tok.ident = buf.String()
+	return tok
+}
@@ -103,6 +103,150 @@ var _ = Describe("Scanner", func() {
 			}
 		})
 	})
+	Context("with note number commands", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("n60 N72"))
+		})
+		It("scans note number tokens followed by numerics", func() {
+			scanner := mml.NewScanner(input)
+
+			expectedTokens := []testTok{
+				testTok{typ: mml.TNoteNumber, ident: "n", lineNum: 1, colNum: 1},
+				testTok{typ: mml.TNumeric, ident: "60", lineNum: 1, colNum: 2},
+				testTok{typ: mml.TNoteNumber, ident: "N", lineNum: 1, colNum: 5},
+				testTok{typ: mml.TNumeric, ident: "72", lineNum: 1, colNum: 6},
+				testTok{typ: mml.TEOF, ident: string(rune(0)), lineNum: 1, colNum: 8},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+	})
+	Context("with key commands", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("kE-c KF#m\nka-ma k\nc="))
+		})
+		It("scans the key name as the identifier of the key token", func() {
+			scanner := mml.NewScanner(input)
+
+			expectedTokens := []testTok{
+				testTok{typ: mml.TKey, ident: "E-", lineNum: 1, colNum: 1},
+				testTok{typ: mml.TNote, ident: "c", lineNum: 1, colNum: 4},
+				testTok{typ: mml.TKey, ident: "F#m", lineNum: 1, colNum: 6},
+				testTok{typ: mml.TKey, ident: "a-m", lineNum: 2, colNum: 1},
+				testTok{typ: mml.TNote, ident: "a", lineNum: 2, colNum: 5},
+				testTok{typ: mml.TKey, ident: "", lineNum: 2, colNum: 7},
+				testTok{typ: mml.TNote, ident: "c", lineNum: 3, colNum: 1},
+				testTok{typ: mml.TModifier, ident: "=", lineNum: 3, colNum: 2},
+				testTok{typ: mml.TEOF, ident: string(rune(0)), lineNum: 3, colNum: 3},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+	})
+	Context("with instrument commands", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("@5c"))
+		})
+		It("scans instrument tokens followed by numerics", func() {
+			scanner := mml.NewScanner(input)
+
+			expectedTokens := []testTok{
+				testTok{typ: mml.TInstrument, ident: "@", lineNum: 1, colNum: 1},
+				testTok{typ: mml.TNumeric, ident: "5", lineNum: 1, colNum: 2},
+				testTok{typ: mml.TNote, ident: "c", lineNum: 1, colNum: 3},
+				testTok{typ: mml.TEOF, ident: string(rune(0)), lineNum: 1, colNum: 4},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+	})
+	Context("with markers", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("*verse_2 c*\n"))
+		})
+		It("scans the name of the marker with the asterisk", func() {
+			scanner := mml.NewScanner(input)
+
+			expectedTokens := []testTok{
+				testTok{typ: mml.TMarker, ident: "*verse_2", lineNum: 1, colNum: 1},
+				testTok{typ: mml.TNote, ident: "c", lineNum: 1, colNum: 10},
+				testTok{typ: mml.TMarker, ident: "*", lineNum: 1, colNum: 11},
+				testTok{typ: mml.TEOF, ident: string(rune(0)), lineNum: 2, colNum: 1},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+	})
+	Context("with a dialect", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("n^k"))
+		})
+		It("only scans the commands of the dialect", func() {
+			scanner := mml.NewDialectScanner(input, mml.ArcheAge)
+
+			expectedTokens := []testTok{
+				testTok{typ: mml.TIllegal, ident: "n", lineNum: 1, colNum: 1},
+				testTok{typ: mml.TTie, ident: "^", lineNum: 1, colNum: 2},
+				testTok{typ: mml.TIllegal, ident: "k", lineNum: 1, colNum: 3},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+	})
+	Context("with comments in the input", func() {
+		It("skips the comments like whitespace", func() {
+			scanner := mml.NewScanner(bytes.NewReader([]byte("/* bar 1 */ a/**/b /* c\n */c")))
+
+			expectedTokens := []testTok{
+				{typ: mml.TNote, ident: "a", lineNum: 1, colNum: 13},
+				{typ: mml.TNote, ident: "b", lineNum: 1, colNum: 18},
+				{typ: mml.TNote, ident: "c", lineNum: 2, colNum: 4},
+				{typ: mml.TEOF, ident: string(rune(0)), lineNum: 2, colNum: 5},
+			}
+			for _, tok := range expectedTokens {
+				token := scanner.Scan()
+				Expect(token.Type()).To(Equal(tok.typ))
+				Expect(token.Ident()).To(Equal(tok.ident))
+				Expect(token.Position()).To(Equal(mml.Position{Line: tok.lineNum, Column: tok.colNum}))
+			}
+		})
+		It("returns TIllegal for a slash that doesn't start a comment", func() {
+			scanner := mml.NewScanner(bytes.NewReader([]byte("a/b")))
+			Expect(scanner.Scan().Ident()).To(Equal("a"))
+			token := scanner.Scan()
+			Expect(token.Type()).To(Equal(mml.TIllegal))
+			Expect(token.Ident()).To(Equal("/"))
+			Expect(scanner.Scan().Ident()).To(Equal("b"))
+		})
+		It("returns TIllegal for a comment that isn't closed", func() {
+			scanner := mml.NewScanner(bytes.NewReader([]byte("a /* b")))
+			Expect(scanner.Scan().Ident()).To(Equal("a"))
+			token := scanner.Scan()
+			Expect(token.Type()).To(Equal(mml.TIllegal))
+			Expect(token.Ident()).To(Equal("/*"))
+			Expect(token.Position()).To(Equal(mml.Position{Line: 1, Column: 3}))
+		})
+	})
 	Context("with unrecognized tokens", func() {
 		BeforeEach(func() {
 			input = bytes.NewReader([]byte("   HABCD"))
@@ -125,4 +269,37 @@ var _ = Describe("Scanner", func() {
 			}
 		})
 	})
+	Context("with multibyte characters in the input", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("c ♪ \nd16"))
+		})
+		It("returns the byte offsets and columns of the span of each token", func() {
+			scanner := mml.NewScanner(input)
+
+			expectedSpans := []mml.Span{
+				{Start: mml.Position{Line: 1, Column: 1}, End: mml.Position{Line: 1, Column: 2}, StartOffset: 0, EndOffset: 1},
+				{Start: mml.Position{Line: 1, Column: 3}, End: mml.Position{Line: 1, Column: 4}, StartOffset: 2, EndOffset: 5},
+				{Start: mml.Position{Line: 2, Column: 1}, End: mml.Position{Line: 2, Column: 2}, StartOffset: 7, EndOffset: 8},
+				{Start: mml.Position{Line: 2, Column: 2}, End: mml.Position{Line: 2, Column: 4}, StartOffset: 8, EndOffset: 10},
+			}
+			for _, span := range expectedSpans {
+				Expect(scanner.Scan().Span()).To(Equal(span))
+			}
+		})
+	})
+	Context("with a key command", func() {
+		BeforeEach(func() {
+			input = bytes.NewReader([]byte("kE- c"))
+		})
+		It("includes the k in the span of the key", func() {
+			scanner := mml.NewScanner(input)
+
+			Expect(scanner.Scan().Span()).To(Equal(mml.Span{
+				Start: mml.Position{Line: 1, Column: 1}, End: mml.Position{Line: 1, Column: 4}, StartOffset: 0, EndOffset: 3,
+			}))
+			Expect(scanner.Scan().Span()).To(Equal(mml.Span{
+				Start: mml.Position{Line: 1, Column: 5}, End: mml.Position{Line: 1, Column: 6}, StartOffset: 4, EndOffset: 5,
+			}))
+		})
+	})
 })
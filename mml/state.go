@@ -3,7 +3,9 @@ package mml
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ff14wed/performgen/encoding"
 )
@@ -13,11 +15,16 @@ import (
 // Executor is an interface on which state changes can be executed
 type Executor interface {
 	EmitNote(note string, modifier string, length int, dot bool) error
+	EmitNoteNumber(number int) error
+	TieNote(note string, modifier string, length int, dot bool) error
 	EmitRest(length int, dot bool) error
 
 	SetTempo(t int) error
 	SetDefaultLength(l int, dot bool) error
 	SetOctave(o int) error
+	SetKey(key string) error
+	SetInstrument(number int) error
+	AddMarker(name string) error
 	CurrentOctave() int
 }
 
@@ -28,12 +35,85 @@ type State struct {
 	Tempo    int
 	Length   int
 	Octave   int
+	Key      string
 
-	dottedLength bool
+	// Dialect changes the defaults and limits of the state. If it is nil, the
+	// Performgen dialect is used.
+	Dialect *Dialect
+	// Instrument limits the notes that can be played. If it is nil, every key
+	// can be played.
+	Instrument *Instrument
+	// ConcertPitch makes notes be written at the pitch that the instrument
+	// sounds instead of the key that plays them, so notes are shifted by the
+	// Shift of the instrument. For example, `o6c` plays `C (+0)` on the flute,
+	// which sounds two octaves higher than the harp. Otherwise notes play the
+	// same key on every instrument.
+	ConcertPitch bool
+	// FoldOctaves moves notes that are out of the range of the instrument by
+	// whole octaves until they're in range, instead of returning an error
+	FoldOctaves bool
+	// MinSpacing is the shortest time between two notes. A note that would be
+	// played sooner is delayed, and the time is taken back from the rests
+	// after it. If it is zero, the MinSpacing of the instrument is used.
+	MinSpacing time.Duration
+	// Warnings are the notes that were delayed before the rests could make up
+	// for an earlier delayed note, in order
+	Warnings []SpacingWarning
+
+	// TempoChanges records every tempo set on the state along with the point
+	// in the sequence where it was set
+	TempoChanges []TempoChange
+	// Markers are the rehearsal markers added to the state, in order
+	Markers []Marker
+
+	// Beats is the number of quarter note beats played so far, which doesn't
+	// depend on the tempo. Notes with a length of 0 don't take up any beats.
+	Beats float64
+	// Elapsed is the time played so far. Unlike the length of the Sequence, it
+	// keeps counting when steps that were already used are removed from it.
+	Elapsed time.Duration
+
+	dottedLength   bool
+	keyAccidentals map[string]int
+	lastNote       encoding.Note
+	// sinceNote is the time emitted since the last note, and late is the time
+	// borrowed to space notes that hasn't been taken back from rests yet
+	sinceNote time.Duration
+	late      time.Duration
 }
 
 var _ Executor = new(State)
 
+// TempoChange is a tempo (in BPM) set at some point in time of a sequence
+type TempoChange struct {
+	At    time.Duration
+	Tempo int
+}
+
+// SpacingWarning is recorded by the state when a note is delayed to be played
+// MinSpacing after the last note, but the last note was already delayed and
+// the rests since then were too short to make up for it
+type SpacingWarning struct {
+	// Beats is the number of quarter note beats played before the note
+	Beats   float64
+	Spacing time.Duration
+	// Late is how much later than written the note is played
+	Late time.Duration
+}
+
+func (w SpacingWarning) String() string {
+	return fmt.Sprintf("the note at beat %g is played %dms late to keep notes %dms apart",
+		w.Beats, w.Late/time.Millisecond, w.Spacing/time.Millisecond)
+}
+
+// Marker is a named point in time of a sequence, like the start of a chorus
+type Marker struct {
+	Name string
+	At   time.Duration
+	// Beats is the number of quarter note beats played before the marker
+	Beats float64
+}
+
 var noteMappings = map[string]int{
 	"C": 1,
 	"D": 3,
@@ -44,35 +124,166 @@ var noteMappings = map[string]int{
 	"B": 12,
 }
 
+// noteFifths is the position of each natural note on the circle of fifths
+// relative to C
+var noteFifths = map[string]int{
+	"F": -1,
+	"C": 0,
+	"G": 1,
+	"D": 2,
+	"A": 3,
+	"E": 4,
+	"B": 5,
+}
+
 // EmitNote emits a music note to the sequence.
 // Modifiers can be one of:
 // `+` or `#` - Makes this note a sharp note
 // `-` - Makes this note a flat note
+// `=` - Makes this note a natural note, ignoring the key signature
+// If there is no modifier, the note is sharpened or flattened according to
+// the key signature set with SetKey.
 // Length is the denominator of 1/x, where the note will be spaced from the
 // next note by 1/x of a beat.
 // If length is -1 (empty length code), the default length will be used.
 // If length is 0 (explicit length code of 0), the length will be set to a
 // very small value (20 milliseconds).
-// If an octave was not specified previously, it will default to octave 3
+// If an octave was not specified previously, it will default to octave 4
 func (s *State) EmitNote(note string, modifier string, length int, dot bool) error {
+	pos, err := s.notePosition(note, modifier)
+	if err != nil {
+		return err
+	}
+	s.emitNote(pos)
+	return s.EmitRest(length, dot)
+}
+
+// notePosition returns the perform note ID of a note at the current octave,
+// folded and shifted for the instrument
+func (s *State) notePosition(note string, modifier string) (encoding.Note, error) {
 	shift := (s.CurrentOctave() - 3) * 12
 	noteMap, ok := noteMappings[strings.ToUpper(note)]
 	if !ok {
-		return fmt.Errorf("invalid note: %s%s", note, modifier)
+		return 0, fmt.Errorf("invalid note: %s%s", note, modifier)
 	}
-	pos := byte(noteMap + shift)
-	if modifier == "#" || modifier == "+" {
+	pos := noteMap + shift
+	switch modifier {
+	case "#", "+":
 		pos++
-	} else if modifier == "-" {
+	case "-":
 		pos--
+	case "":
+		pos += s.keyAccidentals[strings.ToUpper(note)]
+	}
+	n, ok := s.fit(pos)
+	if !ok {
+		lowest, highest := s.noteRange()
+		return 0, &RangeError{
+			Name: "note", Text: note + modifier, Octave: s.CurrentOctave(), Instrument: s.instrumentName(),
+			Value: pos, Min: lowest, Max: highest,
+		}
+	}
+	return n, nil
+}
+
+// noteRange returns the lowest and highest notes that can be written for the
+// instrument as perform note IDs, which are shifted when ConcertPitch is set
+func (s *State) noteRange() (int, int) {
+	inst := s.instrument()
+	lowest, highest := 1, inst.keys()
+	if s.ConcertPitch {
+		lowest, highest = lowest+inst.Shift(), highest+inst.Shift()
+	}
+	return lowest, highest
+}
+
+// fit folds a written note into the range of the instrument if FoldOctaves
+// is set, and returns the key that plays it. It returns false if the note
+// can't be played on the instrument.
+func (s *State) fit(pos int) (encoding.Note, bool) {
+	lowest, highest := s.noteRange()
+	if s.FoldOctaves {
+		for pos < lowest && pos+12 <= highest {
+			pos += 12
+		}
+		for pos > highest && pos-12 >= lowest {
+			pos -= 12
+		}
+	}
+	if pos < lowest || pos > highest {
+		return 0, false
+	}
+	if s.ConcertPitch {
+		pos -= s.instrument().Shift()
 	}
-	if pos < 1 || pos > 37 {
-		return fmt.Errorf("invalid note: %s%s at octave %d", note, modifier, s.CurrentOctave())
+	return encoding.Note(pos), true
+}
+
+// minSpacing returns the shortest time between two notes, which is the
+// MinSpacing of the instrument unless it is set on the state
+func (s *State) minSpacing() time.Duration {
+	if s.MinSpacing != 0 {
+		return s.MinSpacing
+	}
+	return s.instrument().MinSpacing
+}
+
+// emitNote emits a note, delaying it first if it's closer than MinSpacing to
+// the last note. If the last note was delayed too and the rests since then
+// were too short to make up for it, a warning is recorded.
+func (s *State) emitNote(n encoding.Note) {
+	spacing := s.minSpacing()
+	if s.lastNote != 0 && s.sinceNote < spacing {
+		wait := spacing - s.sinceNote
+		s.Sequence = append(s.Sequence, encoding.Delays(int(wait/time.Millisecond))...)
+		s.Elapsed += wait
+		if s.late > 0 {
+			s.Warnings = append(s.Warnings, SpacingWarning{Beats: s.Beats, Spacing: spacing, Late: s.late + wait})
+		}
+		s.late += wait
+	}
+	s.Sequence = append(s.Sequence, n)
+	s.lastNote = n
+	s.sinceNote = 0
+}
+
+// TieNote extends the previous note by the given length if it has the same
+// pitch as this note. Since FFXIV doesn't support sustained notes, this is
+// the same as a rest. Otherwise, the note is emitted like EmitNote.
+func (s *State) TieNote(note string, modifier string, length int, dot bool) error {
+	pos, err := s.notePosition(note, modifier)
+	if err != nil {
+		return err
+	}
+	if pos != s.lastNote {
+		s.emitNote(pos)
 	}
-	s.Sequence = append(s.Sequence, encoding.Note(pos))
 	return s.EmitRest(length, dot)
 }
 
+// noteNumberOffset is the difference between a MIDI note number and a
+// perform note ID. The 37 playable notes are MIDI notes 48 (C3) to 84 (C6).
+const noteNumberOffset = 47
+
+// EmitNoteNumber emits a music note to the sequence by its MIDI note number,
+// where 60 is `C (+0)`, followed by a rest of the default length. The
+// dialect's NoteNumberOffset is added to the number first. Unlike EmitNote,
+// the octave and key signature of the state are not applied, but the note is
+// still folded and shifted for the instrument.
+func (s *State) EmitNoteNumber(number int) error {
+	offset := noteNumberOffset - s.dialect().NoteNumberOffset
+	n, ok := s.fit(number - offset)
+	if !ok {
+		lowest, highest := s.noteRange()
+		return &RangeError{
+			Name: "note number", Text: strconv.Itoa(number), Instrument: s.instrumentName(),
+			Value: number, Min: lowest + offset, Max: highest + offset,
+		}
+	}
+	s.emitNote(n)
+	return s.EmitRest(-1, false)
+}
+
 // EmitRest emits a rest note to the sequence. The length is the same as
 // the length defined by EmitNote.
 func (s *State) EmitRest(length int, dot bool) error {
@@ -87,58 +298,133 @@ func (s *State) EmitRest(length int, dot bool) error {
 		ml = ml + ml/2
 	}
 	s.emitDelay(ml)
+	s.Beats += s.lengthInBeats(length, dot)
 	return nil
 }
 
+// emitDelay emits a delay, shortened by the time borrowed to space notes
 func (s *State) emitDelay(ml uint16) {
-	for ml > 0 {
-		if ml >= 250 {
-			s.Sequence = append(s.Sequence, encoding.Delay(250))
-			ml -= 250
-		} else {
-			s.Sequence = append(s.Sequence, encoding.Delay(byte(ml)))
-			ml = 0
-		}
+	d := time.Duration(ml) * time.Millisecond
+	if s.late > d {
+		s.late -= d
+		return
 	}
+	d -= s.late
+	s.late = 0
+	s.Sequence = append(s.Sequence, encoding.Delays(int(d/time.Millisecond))...)
+	s.Elapsed += d
+	s.sinceNote += d
 }
 
 // SetTempo sets the tempo (in BPM) on the state. If the Tempo is not set,
-// it is assumed the tempo is 120 bpm.
+// it is assumed the tempo is 120 bpm. The tempo must be within the limits of
+// the dialect.
 func (s *State) SetTempo(t int) error {
-	if t < 1 {
-		return errors.New("cannot set tempo to lower than 1")
-	} else if t > 900 {
-		return errors.New("cannot set tempo to greater than 900")
+	d := s.dialect()
+	if t < d.MinTempo || t > d.MaxTempo {
+		return &RangeError{Name: "tempo", Text: strconv.Itoa(t), Value: t, Min: d.MinTempo, Max: d.MaxTempo}
 	}
 	s.Tempo = t
+	s.TempoChanges = append(s.TempoChanges, TempoChange{At: s.Elapsed, Tempo: t})
 	return nil
 }
 
 // SetDefaultLength sets the default length on the state. If the default length
 // is not set, then it is assumed the length is 1/4th of a beat.
 func (s *State) SetDefaultLength(l int, dot bool) error {
-	switch {
-	case l < 0:
-		return errors.New("cannot set default length to less than 0")
-	case l == 0:
-		return errors.New("cannot set default length to 0")
-	case l > 64:
-		return errors.New("cannot set default length to greater than 64")
+	if l < 1 || l > 64 {
+		return &RangeError{Name: "default length", Text: strconv.Itoa(l), Value: l, Min: 1, Max: 64}
 	}
 	s.Length = l
 	s.dottedLength = dot
 	return nil
 }
 
-// SetOctave sets the octave on the state
+// SetOctave sets the octave on the state. The octave must be one of the
+// octaves of the keys, or of the range the instrument sounds in when
+// ConcertPitch is set.
 func (s *State) SetOctave(o int) error {
-	if o < 3 || o > 6 {
-		return errors.New("cannot set octave to anything other than 3, 4, 5, or 6")
+	lowest, highest := 3, 6
+	if s.ConcertPitch {
+		shift := s.instrument().Shift() / 12
+		lowest, highest = lowest+shift, highest+shift
+	}
+	if o < lowest || o > highest {
+		return &RangeError{Name: "octave", Text: strconv.Itoa(o), Value: o, Min: lowest, Max: highest}
 	}
 	s.Octave = o
 	return nil
 }
 
+// SetKey sets the key signature on the state. Keys are written as a note
+// name, an optional `+`, `#`, or `-` modifier, and an `m` suffix for minor
+// keys, like `E-` for E flat major or `F#m` for F sharp minor. Setting the
+// key to `C` or `Am` clears the key signature.
+func (s *State) SetKey(key string) error {
+	k := strings.ToUpper(key)
+	if k == "" {
+		return errors.New("invalid key: key is empty")
+	}
+	fifths, ok := noteFifths[k[:1]]
+	if !ok {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+	rest := k[1:]
+	if strings.HasPrefix(rest, "#") || strings.HasPrefix(rest, "+") {
+		fifths += 7
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "-") {
+		fifths -= 7
+		rest = rest[1:]
+	}
+	if rest == "M" {
+		fifths -= 3
+		rest = ""
+	}
+	if rest != "" || fifths < -7 || fifths > 7 {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+	accidentals := map[string]int{}
+	for i := 0; i < fifths; i++ {
+		accidentals["FCGDAEB"[i:i+1]] = 1
+	}
+	for i := 0; i < -fifths; i++ {
+		accidentals["BEADGCF"[i:i+1]] = -1
+	}
+	s.Key = key
+	s.keyAccidentals = accidentals
+	return nil
+}
+
+// SetInstrument selects the instrument with the given number in game, which
+// changes the notes that can be played from then on. If notes have already
+// been played on another instrument, it also emits a tone change so that the
+// performer switches to the instrument in the middle of the song. The
+// instrument the song starts with is picked by the performer instead.
+func (s *State) SetInstrument(number int) error {
+	i := instrumentNumber(number)
+	if i == nil {
+		return fmt.Errorf("unknown instrument number: %d", number)
+	}
+	if s.lastNote != 0 && s.Instrument != i {
+		s.Sequence = append(s.Sequence, encoding.ToneChange(number))
+	}
+	s.Instrument = i
+	return nil
+}
+
+// AddMarker records a rehearsal marker with the given name at the current
+// point of the sequence. Each marker of a track must have a different name.
+func (s *State) AddMarker(name string) error {
+	for _, m := range s.Markers {
+		if m.Name == name {
+			return fmt.Errorf("duplicate marker: %s", name)
+		}
+	}
+	s.Markers = append(s.Markers, Marker{Name: name, At: s.Elapsed, Beats: s.Beats})
+	return nil
+}
+
 // CurrentOctave returns the current octave on the state
 func (s *State) CurrentOctave() int {
 	if s.Octave == 0 {
@@ -147,6 +433,29 @@ func (s *State) CurrentOctave() int {
 	return s.Octave
 }
 
+func (s *State) dialect() *Dialect {
+	if s.Dialect == nil {
+		return Performgen
+	}
+	return s.Dialect
+}
+
+func (s *State) instrument() *Instrument {
+	if s.Instrument == nil {
+		return defaultInstrument
+	}
+	return s.Instrument
+}
+
+// instrumentName returns the name of the selected instrument, which is empty
+// if no instrument is selected
+func (s *State) instrumentName() string {
+	if s.Instrument == nil {
+		return ""
+	}
+	return s.Instrument.Name
+}
+
 // lengthInMs calculates the amount of delay required to achieve a length
 // given a certain tempo
 func (s *State) lengthInMs(lengthDenom int) (uint16, error) {
@@ -169,3 +478,27 @@ func (s *State) lengthInMs(lengthDenom int) (uint16, error) {
 	length := 4 * msPerBeat / float64(lengthDenom)
 	return uint16(length), nil
 }
+
+// lengthInBeats returns the number of quarter note beats of a rest emitted
+// by EmitRest
+func (s *State) lengthInBeats(lengthDenom int, dot bool) float64 {
+	if lengthDenom == 0 {
+		return 0
+	}
+	beats := 4.0
+	if lengthDenom == -1 {
+		beats = 1
+		if s.Length != 0 {
+			beats = 4 / float64(s.Length)
+		}
+		if s.dottedLength {
+			beats *= 1.5
+		}
+	} else {
+		beats /= float64(lengthDenom)
+	}
+	if dot {
+		beats *= 1.5
+	}
+	return beats
+}
@@ -1,6 +1,8 @@
 package mml_test
 
 import (
+	"time"
+
 	"github.com/ff14wed/performgen/encoding"
 	"github.com/ff14wed/performgen/mml"
 	. "github.com/onsi/ginkgo"
@@ -113,6 +115,27 @@ var _ = Describe("State", func() {
 			Expect(s.EmitNote("a", "", 0, false)).To(Succeed())
 			Expect(s.Sequence).To(ConsistOf(encoding.Note(22), encoding.Delay(20)))
 		})
+		Context("when a key is set", func() {
+			BeforeEach(func() {
+				Expect(s.SetKey("E-")).To(Succeed())
+			})
+			It("applies the key signature to notes without a modifier", func() {
+				Expect(s.EmitNote("b", "", 0, false)).To(Succeed())
+				Expect(s.EmitNote("d", "", 0, false)).To(Succeed())
+				Expect(s.Sequence).To(Equal(encoding.Sequence{
+					encoding.Note(23), encoding.Delay(20),
+					encoding.Note(15), encoding.Delay(20),
+				}))
+			})
+			It("cancels the key signature with a natural modifier", func() {
+				Expect(s.EmitNote("b", "=", 0, false)).To(Succeed())
+				Expect(s.Sequence).To(ConsistOf(encoding.Note(24), encoding.Delay(20)))
+			})
+			It("does not apply the key signature to notes with a modifier", func() {
+				Expect(s.EmitNote("e", "#", 0, false)).To(Succeed())
+				Expect(s.Sequence).To(ConsistOf(encoding.Note(18), encoding.Delay(20)))
+			})
+		})
 		It("errors if an invalid length is provided", func() {
 			Expect(s.EmitNote("C", "", -2, false)).To(MatchError("invalid length: -2"))
 		})
@@ -123,6 +146,67 @@ var _ = Describe("State", func() {
 			s.SetOctave(6)
 			Expect(s.EmitNote("D", "+", 1, false)).To(MatchError("invalid note: D+ at octave 6"))
 		})
+		It("returns a RangeError with the note ID and the range of note IDs", func() {
+			s.SetOctave(3)
+			Expect(s.EmitNote("C", "-", 1, false)).To(MatchError(&mml.RangeError{
+				Name: "note", Text: "C-", Octave: 3, Value: 0, Min: 1, Max: 37,
+			}))
+		})
+	})
+	Describe("EmitNoteNumber", func() {
+		DescribeTable("emits the correct note",
+			func(number, expectedID int) {
+				Expect(s.EmitNoteNumber(number)).To(Succeed())
+				Expect(s.Sequence).To(ConsistOf(encoding.Note(byte(expectedID)), encoding.Delay(250), encoding.Delay(250)))
+			},
+			Entry("C (-1)", 48, 1),
+			Entry("C (+0)", 60, 13),
+			Entry("A (+0)", 69, 22),
+			Entry("C (+2)", 84, 37),
+		)
+		It("ignores the octave and key signature", func() {
+			s.SetOctave(6)
+			Expect(s.SetKey("F")).To(Succeed())
+			Expect(s.EmitNoteNumber(71)).To(Succeed())
+			Expect(s.Sequence[0]).To(Equal(encoding.Note(24)))
+		})
+		It("uses the default length", func() {
+			Expect(s.SetDefaultLength(8, false)).To(Succeed())
+			Expect(s.EmitNoteNumber(60)).To(Succeed())
+			Expect(s.Sequence).To(ConsistOf(encoding.Note(13), encoding.Delay(250)))
+		})
+		It("adds the note number offset of the dialect", func() {
+			s.Dialect = mml.Mabinogi
+			Expect(s.EmitNoteNumber(48)).To(Succeed())
+			Expect(s.Sequence[0]).To(Equal(encoding.Note(13)))
+			Expect(s.EmitNoteNumber(73)).To(MatchError("invalid note number: 73 is out of range (36-72)"))
+		})
+		It("errors if the note number is out of range", func() {
+			Expect(s.EmitNoteNumber(47)).To(MatchError("invalid note number: 47 is out of range (48-84)"))
+			Expect(s.EmitNoteNumber(85)).To(MatchError("invalid note number: 85 is out of range (48-84)"))
+			Expect(s.Sequence).To(BeEmpty())
+		})
+	})
+	Describe("TieNote", func() {
+		It("extends the previous note if it has the same pitch", func() {
+			Expect(s.EmitNote("c", "", 8, false)).To(Succeed())
+			Expect(s.TieNote("c", "", 8, false)).To(Succeed())
+			Expect(s.Sequence).To(Equal(encoding.Sequence{
+				encoding.Note(13), encoding.Delay(250),
+				encoding.Delay(250),
+			}))
+		})
+		It("emits the note if it has a different pitch", func() {
+			Expect(s.EmitNote("c", "", 8, false)).To(Succeed())
+			Expect(s.TieNote("c", "+", 8, false)).To(Succeed())
+			Expect(s.Sequence).To(Equal(encoding.Sequence{
+				encoding.Note(13), encoding.Delay(250),
+				encoding.Note(14), encoding.Delay(250),
+			}))
+		})
+		It("errors if an invalid note is provided", func() {
+			Expect(s.TieNote("H", "", 8, false)).To(MatchError("invalid note: H"))
+		})
 	})
 	Describe("EmitRest", func() {
 		It("emits a default (quarter note) rest at 120bpm", func() {
@@ -165,6 +249,18 @@ var _ = Describe("State", func() {
 				Expect(s.Sequence).To(ConsistOf(encoding.Delay(62)))
 			})
 		})
+		It("counts the quarter note beats regardless of the tempo", func() {
+			Expect(s.SetTempo(60)).To(Succeed())
+			Expect(s.EmitRest(2, false)).To(Succeed())
+			Expect(s.Beats).To(Equal(2.0))
+			Expect(s.SetDefaultLength(8, true)).To(Succeed())
+			Expect(s.EmitRest(-1, false)).To(Succeed())
+			Expect(s.Beats).To(Equal(2.75))
+			Expect(s.EmitRest(-1, true)).To(Succeed())
+			Expect(s.Beats).To(Equal(3.875))
+			Expect(s.EmitRest(0, false)).To(Succeed())
+			Expect(s.Beats).To(Equal(3.875))
+		})
 		Context("with provided tempo and provided length", func() {
 			BeforeEach(func() {
 				s.SetTempo(60)
@@ -189,6 +285,29 @@ var _ = Describe("State", func() {
 			Expect(s.SetTempo(900)).To(Succeed())
 			Expect(s.Tempo).To(Equal(900))
 		})
+		It("records when each tempo was set", func() {
+			Expect(s.SetTempo(60)).To(Succeed())
+			Expect(s.EmitRest(4, false)).To(Succeed())
+			Expect(s.SetTempo(240)).To(Succeed())
+			Expect(s.TempoChanges).To(Equal([]mml.TempoChange{
+				{At: 0, Tempo: 60},
+				{At: time.Second, Tempo: 240},
+			}))
+		})
+		It("records the time played even if the sequence was truncated", func() {
+			Expect(s.EmitRest(4, false)).To(Succeed())
+			s.Sequence = s.Sequence[:0]
+			Expect(s.EmitRest(4, false)).To(Succeed())
+			Expect(s.SetTempo(60)).To(Succeed())
+			Expect(s.Elapsed).To(Equal(time.Second))
+			Expect(s.TempoChanges).To(Equal([]mml.TempoChange{{At: time.Second, Tempo: 60}}))
+		})
+		It("uses the tempo limits of the dialect", func() {
+			s.Dialect = mml.Mabinogi
+			Expect(s.SetTempo(31)).To(MatchError("cannot set tempo to lower than 32"))
+			Expect(s.SetTempo(256)).To(MatchError("cannot set tempo to greater than 255"))
+			Expect(s.SetTempo(255)).To(Succeed())
+		})
 		It("errors if the tempo is less than 1", func() {
 			Expect(s.SetTempo(0)).To(MatchError("cannot set tempo to lower than 1"))
 			Expect(s.Tempo).To(Equal(0))
@@ -269,6 +388,212 @@ var _ = Describe("State", func() {
 			Expect(s.Length).To(Equal(0))
 		})
 	})
+	Describe("SetKey", func() {
+		DescribeTable("sets the accidentals of the key signature",
+			func(key string, expectedIDs []int) {
+				Expect(s.SetKey(key)).To(Succeed())
+				Expect(s.Key).To(Equal(key))
+				for _, note := range []string{"C", "D", "E", "F", "G", "A", "B"} {
+					Expect(s.EmitNote(note, "", 0, false)).To(Succeed())
+				}
+				var ids []int
+				for _, step := range s.Sequence.Timeline() {
+					ids = append(ids, int(step.Note))
+				}
+				Expect(ids).To(Equal(expectedIDs))
+			},
+			Entry("C major", "C", []int{13, 15, 17, 18, 20, 22, 24}),
+			Entry("A minor", "Am", []int{13, 15, 17, 18, 20, 22, 24}),
+			Entry("G major", "G", []int{13, 15, 17, 19, 20, 22, 24}),
+			Entry("E flat major", "E-", []int{13, 15, 16, 18, 20, 21, 23}),
+			Entry("F sharp minor", "F#m", []int{14, 15, 17, 19, 21, 22, 24}),
+			Entry("B flat minor", "b-m", []int{13, 14, 16, 18, 19, 21, 23}),
+			Entry("C sharp major", "C+", []int{14, 16, 18, 19, 21, 23, 25}),
+		)
+		It("clears the previous key signature", func() {
+			Expect(s.SetKey("D")).To(Succeed())
+			Expect(s.SetKey("C")).To(Succeed())
+			Expect(s.EmitNote("F", "", 0, false)).To(Succeed())
+			Expect(s.Sequence).To(ConsistOf(encoding.Note(18), encoding.Delay(20)))
+		})
+		DescribeTable("errors if the key is invalid",
+			func(key string) {
+				Expect(s.SetKey(key)).To(MatchError("invalid key: " + key))
+				Expect(s.Key).To(BeEmpty())
+			},
+			Entry("unknown note", "H"),
+			Entry("unknown mode", "Cx"),
+			Entry("too many sharps", "G#"),
+			Entry("too many flats", "F-"),
+		)
+	})
+	Describe("SetInstrument", func() {
+		It("selects the instrument with the number", func() {
+			Expect(s.SetInstrument(5)).To(Succeed())
+			Expect(s.Instrument.Name).To(Equal("Flute"))
+			Expect(s.Sequence).To(BeEmpty())
+		})
+		It("emits a tone change if the instrument changes after notes are played", func() {
+			Expect(s.SetInstrument(1)).To(Succeed())
+			Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+			Expect(s.SetInstrument(1)).To(Succeed())
+			Expect(s.SetInstrument(3)).To(Succeed())
+			Expect(s.Sequence).To(Equal(encoding.Sequence{
+				encoding.Note(13), encoding.Delay(20), encoding.ToneChange(3),
+			}))
+		})
+		It("errors if there is no instrument with the number", func() {
+			Expect(s.SetInstrument(0)).To(MatchError("unknown instrument number: 0"))
+			Expect(s.SetInstrument(29)).To(MatchError("unknown instrument number: 29"))
+			Expect(s.Instrument).To(BeNil())
+		})
+		Context("when an instrument is selected", func() {
+			BeforeEach(func() {
+				s.Instrument = &mml.Instrument{Name: "Test", Lowest: 36, Highest: 53}
+			})
+			It("plays the keys that are written", func() {
+				Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+				Expect(s.EmitNoteNumber(60)).To(Succeed())
+				Expect(s.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+					{Note: 13, At: 0},
+					{Note: 13, At: 20 * time.Millisecond},
+				}))
+			})
+			It("errors if the note is out of the range of the instrument", func() {
+				s.SetOctave(5)
+				Expect(s.EmitNote("G", "", 0, false)).To(MatchError(&mml.RangeError{
+					Name: "note", Text: "G", Octave: 5, Instrument: "Test", Value: 32, Min: 1, Max: 18,
+				}))
+				Expect(s.EmitNote("G", "", 0, false)).To(MatchError("invalid note: G at octave 5 on Test"))
+				Expect(s.EmitNoteNumber(66)).To(MatchError("invalid note number: 66 is out of range (48-65) on Test"))
+				Expect(s.Sequence).To(BeEmpty())
+			})
+			It("folds notes into the range of the instrument if FoldOctaves is set", func() {
+				s.FoldOctaves = true
+				s.SetOctave(5)
+				Expect(s.EmitNote("G", "", 0, false)).To(Succeed())
+				s.SetOctave(3)
+				Expect(s.EmitNote("C", "-", 0, false)).To(Succeed())
+				Expect(s.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+					{Note: 8, At: 0},
+					{Note: 12, At: 20 * time.Millisecond},
+				}))
+			})
+			It("errors if the note can't be folded into the range", func() {
+				s.FoldOctaves = true
+				s.Instrument = &mml.Instrument{Name: "Test", Lowest: 48, Highest: 55}
+				Expect(s.EmitNote("A", "", 0, false)).To(MatchError("invalid note: A at octave 4 on Test"))
+			})
+			It("uses the minimum spacing of the instrument unless MinSpacing is set", func() {
+				s.Instrument = &mml.Instrument{Name: "Test", Lowest: 48, Highest: 84, MinSpacing: 30 * time.Millisecond}
+				Expect(s.EmitNote("C", "", 16, false)).To(Succeed())
+				Expect(s.EmitNote("D", "", 0, false)).To(Succeed())
+				Expect(s.EmitNote("E", "", 8, false)).To(Succeed())
+				s.MinSpacing = 100 * time.Millisecond
+				Expect(s.EmitNote("F", "", 8, false)).To(Succeed())
+				Expect(s.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+					{Note: 13, At: 0},
+					{Note: 15, At: 125 * time.Millisecond},
+					{Note: 17, At: 155 * time.Millisecond},
+					{Note: 18, At: 395 * time.Millisecond},
+				}))
+			})
+			Context("with ConcertPitch", func() {
+				BeforeEach(func() {
+					s.ConcertPitch = true
+				})
+				It("shifts the notes to the keys that play them", func() {
+					s.SetOctave(3)
+					Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+					Expect(s.EmitNoteNumber(48)).To(Succeed())
+					Expect(s.TieNote("C", "", 0, false)).To(Succeed())
+					Expect(s.Sequence).To(Equal(encoding.Sequence{
+						encoding.Note(13), encoding.Delay(20),
+						encoding.Note(13), encoding.Delay(250), encoding.Delay(250),
+						encoding.Delay(20),
+					}))
+				})
+				It("errors if the note is out of the range that the instrument sounds", func() {
+					s.SetOctave(4)
+					Expect(s.EmitNote("G", "", 0, false)).To(MatchError(&mml.RangeError{
+						Name: "note", Text: "G", Octave: 4, Instrument: "Test", Value: 20, Min: -11, Max: 6,
+					}))
+					Expect(s.EmitNoteNumber(54)).To(MatchError("invalid note number: 54 is out of range (36-53) on Test"))
+					Expect(s.Sequence).To(BeEmpty())
+				})
+				It("allows the octaves that the instrument sounds in", func() {
+					Expect(s.SetOctave(2)).To(Succeed())
+					Expect(s.SetOctave(6)).To(MatchError("cannot set octave to anything other than 2, 3, 4, or 5"))
+				})
+			})
+		})
+	})
+	Describe("MinSpacing", func() {
+		BeforeEach(func() {
+			s.MinSpacing = 50 * time.Millisecond
+		})
+		It("delays notes that are too close and takes the time back from the next rest", func() {
+			Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+			Expect(s.EmitNote("D", "", 0, false)).To(Succeed())
+			Expect(s.EmitRest(8, false)).To(Succeed())
+			Expect(s.EmitNote("E", "", 0, false)).To(Succeed())
+			Expect(s.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+				{Note: 13, At: 0},
+				{Note: 15, At: 50 * time.Millisecond},
+				{Note: 17, At: 290 * time.Millisecond},
+			}))
+			Expect(s.Sequence.Length()).To(Equal(310 * time.Millisecond))
+		})
+		It("doesn't change notes that are far enough apart", func() {
+			Expect(s.EmitNote("C", "", 16, false)).To(Succeed())
+			Expect(s.TieNote("C", "", 0, false)).To(Succeed())
+			Expect(s.EmitNoteNumber(62)).To(Succeed())
+			Expect(s.Sequence).To(Equal(encoding.Sequence{
+				encoding.Note(13), encoding.Delay(125), encoding.Delay(20),
+				encoding.Note(15), encoding.Delay(250), encoding.Delay(250),
+			}))
+		})
+		It("warns if the rests are too short to make up for a delayed note", func() {
+			Expect(s.EmitNote("C", "", 0, false)).To(Succeed())
+			Expect(s.EmitNote("D", "", 0, false)).To(Succeed())
+			Expect(s.Warnings).To(BeEmpty())
+			Expect(s.EmitNote("E", "", 0, false)).To(Succeed())
+			Expect(s.EmitRest(8, false)).To(Succeed())
+			Expect(s.EmitNote("F", "", 0, false)).To(Succeed())
+			Expect(s.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+				{Note: 13, At: 0},
+				{Note: 15, At: 50 * time.Millisecond},
+				{Note: 17, At: 100 * time.Millisecond},
+				{Note: 18, At: 310 * time.Millisecond},
+			}))
+			Expect(s.Warnings).To(Equal([]mml.SpacingWarning{
+				{Beats: 0, Spacing: 50 * time.Millisecond, Late: 60 * time.Millisecond},
+			}))
+			Expect(s.Warnings[0].String()).To(Equal("the note at beat 0 is played 60ms late to keep notes 50ms apart"))
+		})
+	})
+	Describe("AddMarker", func() {
+		It("records the marker at the current time and beat", func() {
+			Expect(s.AddMarker("intro")).To(Succeed())
+			Expect(s.EmitNote("C", "", 8, false)).To(Succeed())
+			Expect(s.AddMarker("chorus")).To(Succeed())
+			Expect(s.Markers).To(Equal([]mml.Marker{
+				{Name: "intro", At: 0, Beats: 0},
+				{Name: "chorus", At: 250 * time.Millisecond, Beats: 0.5},
+			}))
+		})
+		It("records the time played even if the sequence was truncated", func() {
+			Expect(s.EmitNote("C", "", 4, false)).To(Succeed())
+			s.Sequence = s.Sequence[:0]
+			Expect(s.AddMarker("chorus")).To(Succeed())
+			Expect(s.Markers).To(Equal([]mml.Marker{{Name: "chorus", At: 500 * time.Millisecond, Beats: 1}}))
+		})
+		It("errors if a marker with the same name was already added", func() {
+			Expect(s.AddMarker("chorus")).To(Succeed())
+			Expect(s.AddMarker("chorus")).To(MatchError("duplicate marker: chorus"))
+			Expect(s.Markers).To(HaveLen(1))
+		})
+	})
 	Describe("CurrentOctave", func() {
 		It("returns the current octave", func() {
 			s.Octave = 9000
@@ -0,0 +1,200 @@
+package musicxml
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ff14wed/performgen/encoding"
+)
+
+// Options selects what part of a score is converted
+type Options struct {
+	// Part is the ID or the name of the part to convert. If empty, the first
+	// part of the score is converted.
+	Part string
+	// Voice is the voice of the part to convert. If empty, all voices of the
+	// part are merged.
+	Voice string
+}
+
+// onset is the start of a note that should be played
+type onset struct {
+	pos  float64
+	note byte
+}
+
+// Convert converts a part of the score to a sequence of perform steps.
+// Repeats are expanded, tied notes are merged into the first note of the tie,
+// and the notes of a chord are arpeggiated with encoding.ChordDelay
+// milliseconds in between each note. Since FFXIV doesn't support sustained
+// notes, the length of a note only affects the delay before the next note is
+// played.
+func Convert(score *Score, opts Options) (encoding.Sequence, error) {
+	seq, _, err := ConvertWithTempos(score, opts)
+	return seq, err
+}
+
+// ConvertWithTempos converts a part of the score like Convert, and also
+// returns the tempo changes of the score with their positions in quarter notes
+func ConvertWithTempos(score *Score, opts Options) (encoding.Sequence, []encoding.TempoChange, error) {
+	part, err := findPart(score, opts.Part)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		onsets    []onset
+		tempos    = []encoding.TempoChange{{Beat: 0, BPM: 120}}
+		divisions = 1
+		start     float64
+	)
+	for _, idx := range playOrder(part.Measures) {
+		m := &part.Measures[idx]
+		var cursor, end, lastOnset float64
+		for _, el := range m.Elements {
+			switch e := el.(type) {
+			case *Attributes:
+				if e.Divisions > 0 {
+					divisions = e.Divisions
+				}
+			case *Sound:
+				if e.Tempo > 0 {
+					tempos = append(tempos, encoding.TempoChange{Beat: start + cursor, BPM: e.Tempo})
+				}
+			case *Direction:
+				if bpm := e.bpm(); bpm > 0 {
+					tempos = append(tempos, encoding.TempoChange{Beat: start + cursor, BPM: bpm})
+				}
+			case *Backup:
+				cursor -= float64(e.Duration) / float64(divisions)
+			case *Forward:
+				cursor += float64(e.Duration) / float64(divisions)
+			case *Note:
+				if e.Grace != nil {
+					continue
+				}
+				pos := cursor
+				if e.Chord != nil {
+					pos = lastOnset
+				} else {
+					lastOnset = cursor
+					cursor += e.quarters(divisions)
+				}
+				if e.Rest != nil || e.Pitch == nil || e.tiedStop() {
+					break
+				}
+				if opts.Voice != "" && e.voice() != opts.Voice {
+					break
+				}
+				id, err := noteID(e.Pitch)
+				if err != nil {
+					return nil, nil, fmt.Errorf("measure %s: %s", m.Number, err)
+				}
+				onsets = append(onsets, onset{pos: start + pos, note: id})
+			}
+			if cursor > end {
+				end = cursor
+			}
+		}
+		start += end
+	}
+
+	sort.SliceStable(tempos, func(i, j int) bool { return tempos[i].Beat < tempos[j].Beat })
+
+	notes := make([]encoding.TimedNote, len(onsets))
+	for i, o := range onsets {
+		notes[i] = encoding.TimedNote{Note: encoding.Note(o.note), At: encoding.TimeAt(tempos, o.pos)}
+	}
+	return encoding.Arrange(notes, encoding.TimeAt(tempos, start)), tempos, nil
+}
+
+func findPart(score *Score, name string) (*Part, error) {
+	if len(score.Parts) == 0 {
+		return nil, fmt.Errorf("score has no parts")
+	}
+	if name == "" {
+		return &score.Parts[0], nil
+	}
+	id := name
+	for _, sp := range score.PartList {
+		if strings.EqualFold(sp.Name, name) {
+			id = sp.ID
+			break
+		}
+	}
+	for i := range score.Parts {
+		if score.Parts[i].ID == id {
+			return &score.Parts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("part not found: %s", name)
+}
+
+var stepOffsets = map[string]int{
+	"C": 0,
+	"D": 2,
+	"E": 4,
+	"F": 5,
+	"G": 7,
+	"A": 9,
+	"B": 11,
+}
+
+// noteID converts a pitch to the perform key ID. C3 (MIDI note 48) is the
+// lowest playable key and C6 (MIDI note 84) is the highest.
+func noteID(p *Pitch) (byte, error) {
+	offset, ok := stepOffsets[strings.ToUpper(p.Step)]
+	if !ok {
+		return 0, fmt.Errorf("invalid pitch step: %s", p.Step)
+	}
+	midi := (p.Octave+1)*12 + offset + int(math.Round(p.Alter))
+	id := midi - 47
+	if id < 1 || id > 37 {
+		return 0, fmt.Errorf("pitch %s is out of range", p)
+	}
+	return byte(id), nil
+}
+
+// playOrder returns the indices of the measures in the order that they are
+// played after expanding repeats and alternate endings
+func playOrder(measures []Measure) []int {
+	var (
+		order    []int
+		start    = 0
+		pass     = 1
+		skipping = false
+	)
+	for i := 0; i < len(measures); i++ {
+		forward, backward, ending, endingCloses := measures[i].barlines()
+		if forward && i != start {
+			start, pass = i, 1
+		}
+		if ending != nil && !ending.includes(pass) {
+			skipping = true
+		}
+		if skipping {
+			if endingCloses {
+				skipping = false
+			}
+			continue
+		}
+		order = append(order, i)
+		if backward != nil {
+			times := backward.Times
+			if times < 2 {
+				times = 2
+			}
+			if pass < times {
+				pass++
+				i = start - 1
+				continue
+			}
+			start, pass = i+1, 1
+		} else if endingCloses {
+			start, pass = i+1, 1
+		}
+	}
+	return order
+}
@@ -0,0 +1,178 @@
+package musicxml_test
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/musicxml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func parseScore(measures string) *musicxml.Score {
+	score, err := musicxml.Parse(strings.NewReader(`<score-partwise>
+  <part-list>
+    <score-part id="P1"><part-name>Lute</part-name></score-part>
+    <score-part id="P2"><part-name>Harp</part-name></score-part>
+  </part-list>
+  <part id="P1">` + measures + `</part>
+  <part id="P2"><measure number="1"><attributes><divisions>1</divisions></attributes>
+    <note><pitch><step>C</step><octave>3</octave></pitch><duration>1</duration></note>
+  </measure></part>
+</score-partwise>`))
+	Expect(err).ToNot(HaveOccurred())
+	return score
+}
+
+func note(step string, octave, duration int, extra string) string {
+	return `<note><pitch><step>` + step + `</step><octave>` + strconv.Itoa(octave) +
+		`</octave></pitch><duration>` + strconv.Itoa(duration) + `</duration>` + extra + `</note>`
+}
+
+var _ = Describe("Convert", func() {
+	It("converts notes and rests at the default tempo", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>2</divisions></attributes>
+      ` + note("C", 4, 2, "") + `
+      <note><rest/><duration>1</duration></note>
+      ` + note("C", 6, 1, "") + `
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(13), encoding.Delay(250), encoding.Delay(250), encoding.Delay(250),
+			encoding.Note(37), encoding.Delay(250),
+		}))
+	})
+	It("merges tied notes and applies tempo markings", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      <direction><sound tempo="60"/></direction>
+      ` + note("A", 3, 1, `<tie type="start"/>`) + `
+      ` + note("A", 3, 1, `<tie type="stop"/>`) + `
+    </measure>
+    <measure number="2">
+      <direction><direction-type><metronome><beat-unit>half</beat-unit><per-minute>60</per-minute></metronome></direction-type></direction>
+      ` + note("B", 3, 1, "") + `
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(10),
+			encoding.Delay(250), encoding.Delay(250), encoding.Delay(250), encoding.Delay(250),
+			encoding.Delay(250), encoding.Delay(250), encoding.Delay(250), encoding.Delay(250),
+			encoding.Note(12), encoding.Delay(250), encoding.Delay(250),
+		}))
+	})
+	It("returns the tempo changes in quarter notes", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      <direction><sound tempo="60"/></direction>
+      ` + note("A", 3, 2, "") + `
+    </measure>
+    <measure number="2">
+      <direction><direction-type><metronome><beat-unit>half</beat-unit><per-minute>60</per-minute></metronome></direction-type></direction>
+      ` + note("B", 3, 1, "") + `
+    </measure>`)
+		_, tempos, err := musicxml.ConvertWithTempos(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tempos).To(Equal([]encoding.TempoChange{{Beat: 0, BPM: 120}, {Beat: 0, BPM: 60}, {Beat: 2, BPM: 120}}))
+	})
+	It("arpeggiates chords without shifting the following notes", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      ` + note("C", 4, 1, "") + `
+      ` + note("E", 4, 1, "<chord/>") + `
+      ` + note("G", 4, 1, "<chord/>") + `
+      ` + note("C", 5, 1, "") + `
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(13), encoding.Delay(20),
+			encoding.Note(17), encoding.Delay(20),
+			encoding.Note(20), encoding.Delay(250), encoding.Delay(210),
+			encoding.Note(25), encoding.Delay(250), encoding.Delay(250),
+		}))
+	})
+	It("uses the duration of tuplet notes", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>3</divisions></attributes>
+      ` + note("C", 4, 1, `<time-modification><actual-notes>3</actual-notes><normal-notes>2</normal-notes></time-modification>`) + `
+      ` + note("D", 4, 1, `<time-modification><actual-notes>3</actual-notes><normal-notes>2</normal-notes></time-modification>`) + `
+      ` + note("E", 4, 1, `<time-modification><actual-notes>3</actual-notes><normal-notes>2</normal-notes></time-modification>`) + `
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(13), encoding.Delay(167),
+			encoding.Note(15), encoding.Delay(166),
+			encoding.Note(17), encoding.Delay(167),
+		}))
+	})
+	It("expands repeats and alternate endings", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      <direction><sound tempo="240"/></direction>
+      <barline location="left"><repeat direction="forward"/></barline>
+      ` + note("C", 4, 1, "") + `
+    </measure>
+    <measure number="2">
+      <barline location="left"><ending number="1" type="start"/></barline>
+      ` + note("D", 4, 1, "") + `
+      <barline location="right"><ending number="1" type="stop"/><repeat direction="backward"/></barline>
+    </measure>
+    <measure number="3">
+      <barline location="left"><ending number="2" type="start"/></barline>
+      ` + note("E", 4, 1, "") + `
+      <barline location="right"><ending number="2" type="discontinue"/></barline>
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(13), encoding.Delay(250),
+			encoding.Note(15), encoding.Delay(250),
+			encoding.Note(13), encoding.Delay(250),
+			encoding.Note(17), encoding.Delay(250),
+		}))
+	})
+	It("selects a single voice after a backup", func() {
+		score := parseScore(`<measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      ` + note("C", 4, 2, "<voice>1</voice>") + `
+      <backup><duration>2</duration></backup>
+      ` + note("G", 3, 1, "<voice>2</voice>") + `
+      ` + note("A", 3, 1, "<voice>2</voice>") + `
+    </measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{Voice: "2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{
+			encoding.Note(8), encoding.Delay(250), encoding.Delay(250),
+			encoding.Note(10), encoding.Delay(250), encoding.Delay(250),
+		}))
+	})
+	It("selects a part by name or ID", func() {
+		score := parseScore(`<measure number="1"></measure>`)
+		seq, err := musicxml.Convert(score, musicxml.Options{Part: "harp"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Delay(250), encoding.Delay(250)}))
+		seq, err = musicxml.Convert(score, musicxml.Options{Part: "P2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seq).To(Equal(encoding.Sequence{encoding.Note(1), encoding.Delay(250), encoding.Delay(250)}))
+	})
+	It("errors if the part doesn't exist", func() {
+		score := parseScore(`<measure number="1"></measure>`)
+		_, err := musicxml.Convert(score, musicxml.Options{Part: "Drum"})
+		Expect(err).To(MatchError("part not found: Drum"))
+	})
+	It("errors if a pitch is out of range", func() {
+		score := parseScore(`<measure number="7">
+      <attributes><divisions>1</divisions></attributes>
+      ` + note("D", 6, 1, "") + `
+    </measure>`)
+		_, err := musicxml.Convert(score, musicxml.Options{})
+		Expect(err).To(MatchError("measure 7: pitch D6 is out of range"))
+	})
+})
@@ -0,0 +1,13 @@
+package musicxml_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMusicxml(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Musicxml Suite")
+}
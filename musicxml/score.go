@@ -0,0 +1,334 @@
+package musicxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Score is the root element of a partwise MusicXML document. Only the
+// elements that affect playback are decoded.
+type Score struct {
+	XMLName  xml.Name    `xml:"score-partwise"`
+	PartList []ScorePart `xml:"part-list>score-part"`
+	Parts    []Part      `xml:"part"`
+}
+
+// ScorePart describes a part listed in the part-list of a score
+type ScorePart struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"part-name"`
+}
+
+// Part is a single part (usually one instrument) of a score
+type Part struct {
+	ID       string    `xml:"id,attr"`
+	Measures []Measure `xml:"measure"`
+}
+
+// Measure is a single measure of a part. Its elements are kept in document
+// order since backup and forward elements move the time cursor relative to
+// the elements before them.
+type Measure struct {
+	Number   string
+	Elements []Element
+}
+
+// Element is one of the playback relevant children of a measure: *Note,
+// *Backup, *Forward, *Attributes, *Direction, *Sound, or *Barline
+type Element interface {
+	element()
+}
+
+// Note is a note, rest, or chord member
+type Note struct {
+	Chord            *struct{}         `xml:"chord"`
+	Grace            *struct{}         `xml:"grace"`
+	Rest             *struct{}         `xml:"rest"`
+	Pitch            *Pitch            `xml:"pitch"`
+	Duration         int               `xml:"duration"`
+	Ties             []Tie             `xml:"tie"`
+	Voice            string            `xml:"voice"`
+	Type             string            `xml:"type"`
+	Dots             []struct{}        `xml:"dot"`
+	TimeModification *TimeModification `xml:"time-modification"`
+	Notations        Notations         `xml:"notations"`
+}
+
+// Pitch is the pitch of a note
+type Pitch struct {
+	Step   string  `xml:"step"`
+	Alter  float64 `xml:"alter"`
+	Octave int     `xml:"octave"`
+}
+
+// String returns the scientific pitch notation of the pitch, such as Bb3
+func (p *Pitch) String() string {
+	alter := int(math.Round(p.Alter))
+	accidental := strings.Repeat("#", alter)
+	if alter < 0 {
+		accidental = strings.Repeat("b", -alter)
+	}
+	return fmt.Sprintf("%s%s%d", p.Step, accidental, p.Octave)
+}
+
+// Tie marks the start or stop of a tied note
+type Tie struct {
+	Type string `xml:"type,attr"`
+}
+
+// TimeModification describes the ratio of a tuplet
+type TimeModification struct {
+	ActualNotes int `xml:"actual-notes"`
+	NormalNotes int `xml:"normal-notes"`
+}
+
+// Notations contains the notations attached to a note
+type Notations struct {
+	Tied    []Tie    `xml:"tied"`
+	Tuplets []Tuplet `xml:"tuplet"`
+}
+
+// Tuplet marks the start or stop of a tuplet bracket
+type Tuplet struct {
+	Type string `xml:"type,attr"`
+}
+
+// Backup moves the time cursor backwards
+type Backup struct {
+	Duration int `xml:"duration"`
+}
+
+// Forward moves the time cursor forwards
+type Forward struct {
+	Duration int    `xml:"duration"`
+	Voice    string `xml:"voice"`
+}
+
+// Attributes changes the musical attributes of a part
+type Attributes struct {
+	Divisions int `xml:"divisions"`
+}
+
+// Direction is a musical direction such as a tempo marking
+type Direction struct {
+	Metronome *Metronome `xml:"direction-type>metronome"`
+	Sound     *Sound     `xml:"sound"`
+}
+
+// Metronome is a printed metronome marking
+type Metronome struct {
+	BeatUnit    string     `xml:"beat-unit"`
+	BeatUnitDot []struct{} `xml:"beat-unit-dot"`
+	PerMinute   float64    `xml:"per-minute"`
+}
+
+// Sound describes playback changes such as tempo
+type Sound struct {
+	Tempo float64 `xml:"tempo,attr"`
+}
+
+// Barline is a barline, which may contain repeats and endings
+type Barline struct {
+	Location string  `xml:"location,attr"`
+	Repeat   *Repeat `xml:"repeat"`
+	Ending   *Ending `xml:"ending"`
+}
+
+// Repeat is a forward or backward repeat sign
+type Repeat struct {
+	Direction string `xml:"direction,attr"`
+	Times     int    `xml:"times,attr"`
+}
+
+// Ending is a volta bracket for alternate endings of a repeat
+type Ending struct {
+	Number string `xml:"number,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func (*Note) element()       {}
+func (*Backup) element()     {}
+func (*Forward) element()    {}
+func (*Attributes) element() {}
+func (*Direction) element()  {}
+func (*Sound) element()      {}
+func (*Barline) element()    {}
+
+// UnmarshalXML decodes the children of a measure in document order
+func (m *Measure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "number" {
+			m.Number = attr.Value
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var el Element
+			switch t.Name.Local {
+			case "note":
+				el = new(Note)
+			case "backup":
+				el = new(Backup)
+			case "forward":
+				el = new(Forward)
+			case "attributes":
+				el = new(Attributes)
+			case "direction":
+				el = new(Direction)
+			case "sound":
+				el = new(Sound)
+			case "barline":
+				el = new(Barline)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(el, &t); err != nil {
+				return err
+			}
+			m.Elements = append(m.Elements, el)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// Parse decodes a partwise MusicXML document
+func Parse(r io.Reader) (*Score, error) {
+	score := new(Score)
+	d := xml.NewDecoder(r)
+	// MusicXML documents are UTF-8 in practice, but some exporters still
+	// declare other encodings in the header.
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := d.Decode(score); err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+// tiedStop returns true if the note continues a tie from the previous note
+func (n *Note) tiedStop() bool {
+	for _, t := range n.Ties {
+		if t.Type == "stop" {
+			return true
+		}
+	}
+	for _, t := range n.Notations.Tied {
+		if t.Type == "stop" {
+			return true
+		}
+	}
+	return false
+}
+
+// voice returns the voice of the note, defaulting to voice 1
+func (n *Note) voice() string {
+	if n.Voice == "" {
+		return "1"
+	}
+	return n.Voice
+}
+
+var typeLengths = map[string]float64{
+	"long":    16,
+	"breve":   8,
+	"whole":   4,
+	"half":    2,
+	"quarter": 1,
+	"eighth":  0.5,
+	"16th":    0.25,
+	"32nd":    0.125,
+	"64th":    0.0625,
+	"128th":   0.03125,
+}
+
+// quarters returns the length of the note in quarter notes. The duration
+// element is authoritative, but if it is missing the length is derived from
+// the note type, dots, and tuplet ratio.
+func (n *Note) quarters(divisions int) float64 {
+	if n.Duration > 0 {
+		return float64(n.Duration) / float64(divisions)
+	}
+	length := typeLengths[n.Type]
+	dotLength := length
+	for range n.Dots {
+		dotLength /= 2
+		length += dotLength
+	}
+	if tm := n.TimeModification; tm != nil && tm.ActualNotes > 0 && tm.NormalNotes > 0 {
+		length = length * float64(tm.NormalNotes) / float64(tm.ActualNotes)
+	}
+	return length
+}
+
+// bpm returns the tempo in quarter notes per minute specified by the
+// direction, or 0 if the direction doesn't specify a tempo
+func (d *Direction) bpm() float64 {
+	if d.Sound != nil && d.Sound.Tempo > 0 {
+		return d.Sound.Tempo
+	}
+	if m := d.Metronome; m != nil && m.PerMinute > 0 {
+		unit, ok := typeLengths[m.BeatUnit]
+		if !ok {
+			unit = 1
+		}
+		dotLength := unit
+		for range m.BeatUnitDot {
+			dotLength /= 2
+			unit += dotLength
+		}
+		return m.PerMinute * unit
+	}
+	return 0
+}
+
+// barlines returns the repeats and endings marked on the barlines of a
+// measure. ending is only set if an ending bracket starts on this measure.
+func (m *Measure) barlines() (forward bool, backward *Repeat, ending *Ending, endingCloses bool) {
+	for _, el := range m.Elements {
+		b, ok := el.(*Barline)
+		if !ok {
+			continue
+		}
+		if b.Repeat != nil {
+			switch b.Repeat.Direction {
+			case "forward":
+				forward = true
+			case "backward":
+				backward = b.Repeat
+			}
+		}
+		if b.Ending != nil {
+			switch b.Ending.Type {
+			case "start":
+				ending = b.Ending
+			case "stop", "discontinue":
+				endingCloses = true
+			}
+		}
+	}
+	return
+}
+
+// includes returns true if the ending applies to the given pass
+func (e *Ending) includes(pass int) bool {
+	for _, f := range strings.FieldsFunc(e.Number, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if n, err := strconv.Atoi(f); err == nil && n == pass {
+			return true
+		}
+	}
+	return false
+}
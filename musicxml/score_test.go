@@ -0,0 +1,76 @@
+package musicxml_test
+
+import (
+	"strings"
+
+	"github.com/ff14wed/performgen/musicxml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Score", func() {
+	Describe("Parse", func() {
+		It("decodes parts and keeps measure elements in document order", func() {
+			score, err := musicxml.Parse(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<score-partwise version="3.1">
+  <part-list>
+    <score-part id="P1"><part-name>Flute</part-name></score-part>
+  </part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes><divisions>2</divisions></attributes>
+      <direction>
+        <direction-type><metronome><beat-unit>quarter</beat-unit><per-minute>90</per-minute></metronome></direction-type>
+        <sound tempo="90"/>
+      </direction>
+      <note>
+        <pitch><step>A</step><alter>-1</alter><octave>4</octave></pitch>
+        <duration>2</duration>
+        <tie type="start"/>
+        <voice>1</voice>
+        <type>quarter</type>
+      </note>
+      <backup><duration>2</duration></backup>
+      <note>
+        <rest/>
+        <duration>2</duration>
+        <voice>2</voice>
+      </note>
+      <barline location="right"><repeat direction="backward" times="3"/></barline>
+    </measure>
+  </part>
+</score-partwise>`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(score.PartList).To(Equal([]musicxml.ScorePart{{ID: "P1", Name: "Flute"}}))
+			Expect(score.Parts).To(HaveLen(1))
+			Expect(score.Parts[0].ID).To(Equal("P1"))
+
+			m := score.Parts[0].Measures[0]
+			Expect(m.Number).To(Equal("1"))
+			Expect(m.Elements).To(HaveLen(6))
+			Expect(m.Elements[0]).To(Equal(&musicxml.Attributes{Divisions: 2}))
+			Expect(m.Elements[1]).To(Equal(&musicxml.Direction{
+				Metronome: &musicxml.Metronome{BeatUnit: "quarter", PerMinute: 90},
+				Sound:     &musicxml.Sound{Tempo: 90},
+			}))
+			Expect(m.Elements[2]).To(Equal(&musicxml.Note{
+				Pitch:    &musicxml.Pitch{Step: "A", Alter: -1, Octave: 4},
+				Duration: 2,
+				Ties:     []musicxml.Tie{{Type: "start"}},
+				Voice:    "1",
+				Type:     "quarter",
+			}))
+			Expect(m.Elements[3]).To(Equal(&musicxml.Backup{Duration: 2}))
+			Expect(m.Elements[4]).To(Equal(&musicxml.Note{Rest: &struct{}{}, Duration: 2, Voice: "2"}))
+			Expect(m.Elements[5]).To(Equal(&musicxml.Barline{
+				Location: "right",
+				Repeat:   &musicxml.Repeat{Direction: "backward", Times: 3},
+			}))
+		})
+		It("errors on malformed documents", func() {
+			_, err := musicxml.Parse(strings.NewReader(`<score-partwise><part id="P1">`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
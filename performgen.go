@@ -2,7 +2,6 @@ package performgen
 
 import (
 	"bytes"
-	"fmt"
 
 	"github.com/ff14wed/performgen/encoding"
 	"github.com/ff14wed/performgen/mml"
@@ -19,18 +18,67 @@ import (
 // slowly into the client to prevent filling the buffer faster than data can be
 // consumed.
 func Generate(input string) ([]encoding.PerformSegment, error) {
+	track, err := GenerateTrack(input)
+	if err != nil {
+		return nil, err
+	}
+	return track.Segments, nil
+}
+
+// Track is a generated track along with its rehearsal markers
+type Track struct {
+	Segments []encoding.PerformSegment
+	// Markers are the markers of the MML, like `*chorus`, with the time they
+	// are at in the track. A track can be started at a marker with
+	// GenerateSection.
+	Markers []mml.Marker
+}
+
+// GenerateTrack is like Generate, but also returns the rehearsal markers of
+// the track
+func GenerateTrack(input string) (*Track, error) {
+	state, err := Run(input)
+	if err != nil {
+		return nil, err
+	}
+	return &Track{Segments: state.Sequence.Segments(), Markers: state.Markers}, nil
+}
+
+// Run parses the MML and executes it, returning the resulting state which
+// contains the sequence of notes and delays along with the tempo changes.
+func Run(input string) (*mml.State, error) {
+	return RunDialect(input, mml.Performgen)
+}
+
+// RunDialect is like Run, but parses and executes the MML according to the
+// given dialect.
+func RunDialect(input string, d *mml.Dialect) (*mml.State, error) {
+	state := &mml.State{Dialect: d}
+	if err := RunState(input, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// RunState is like RunDialect, but executes the MML on the given state, so
+// that the instrument of the state can be set beforehand. The MML is parsed
+// according to the dialect of the state.
+func RunState(input string, state *mml.State) error {
+	d := state.Dialect
+	if d == nil {
+		d = mml.Performgen
+	}
 	r := bytes.NewReader([]byte(input))
-	parser := mml.NewParser(r)
+	parser := mml.NewDialectParser(r, d)
 	ast, err := parser.Parse()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	state := new(mml.State)
 	for i, cmd := range ast.Sequence {
 		err = cmd.Execute(state)
 		if err != nil {
-			return nil, fmt.Errorf("execution error at %s: %s", ast.Positions[i], err)
+			return &mml.ExecError{Position: ast.Positions[i], Span: ast.Spans[i], Command: cmd, Err: err}
 		}
 	}
-	return state.Sequence.Segments(), nil
+	return nil
 }
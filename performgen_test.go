@@ -1,16 +1,34 @@
 package performgen_test
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ff14wed/performgen"
 	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+	"github.com/ff14wed/performgen/textnote"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Perform Generator", func() {
+	It("generates a track with the time of each marker", func() {
+		track, err := performgen.GenerateTrack("t120 *intro l8 cdef *chorus g")
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := performgen.Generate("t120 l8 cdefg")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(track.Segments).To(Equal(expected))
+		Expect(track.Markers).To(Equal([]mml.Marker{
+			{Name: "intro", At: 0, Beats: 0},
+			{Name: "chorus", At: time.Second, Beats: 2},
+		}))
+	})
 	It("generates correct perform data blocks from the MML", func() {
 		data, err := performgen.Generate("t88 b2al2b+.")
 		Expect(err).ToNot(HaveOccurred())
@@ -40,4 +58,74 @@ var _ = Describe("Perform Generator", func() {
 		_, err := performgen.Generate(" ABCDo7")
 		Expect(err).To(MatchError("execution error at line 1, column 6: cannot set octave to anything other than 3, 4, 5, or 6"))
 	})
+	It("executes the MML on a state with an instrument", func() {
+		flute, err := mml.LookupInstrument("flute")
+		Expect(err).ToNot(HaveOccurred())
+		state := &mml.State{Instrument: flute}
+		Expect(performgen.RunState("o5c", state)).To(Succeed())
+		Expect(state.Sequence[0]).To(Equal(encoding.Note(25)))
+
+		state = &mml.State{Instrument: flute, ConcertPitch: true}
+		Expect(performgen.RunState("o6c", state)).To(Succeed())
+		Expect(state.Sequence[0]).To(Equal(encoding.Note(13)))
+
+		state = &mml.State{ConcertPitch: true}
+		Expect(performgen.RunState("@1 o4c @5 o6c", state)).To(Succeed())
+		Expect(state.Sequence.Segments()[0].Block.String()).To(Equal("0c0dfffafffafe050dfffafffa00000000000000000000000000000000000000"))
+
+		err = performgen.RunState("@1 o3c @5 c", &mml.State{ConcertPitch: true})
+		Expect(err).To(MatchError("execution error at line 1, column 11: invalid note: c at octave 3 on Flute"))
+	})
+})
+
+var _ = Describe("Dialect conformance", func() {
+	// Each dialect directory contains MML files along with either the notes
+	// they are expected to play (.txt) or the error they produce (.err)
+	dirs, _ := filepath.Glob(filepath.Join("testdata", "dialects", "*"))
+	for _, dir := range dirs {
+		dir := dir
+		Describe(filepath.Base(dir), func() {
+			var dialect *mml.Dialect
+			BeforeEach(func() {
+				var err error
+				dialect, err = mml.LookupDialect(filepath.Base(dir))
+				Expect(err).ToNot(HaveOccurred())
+			})
+			files, _ := filepath.Glob(filepath.Join(dir, "*.mml"))
+			for _, file := range files {
+				file := file
+				base := strings.TrimSuffix(file, ".mml")
+				It(filepath.Base(base), func() {
+					input, err := ioutil.ReadFile(file)
+					Expect(err).ToNot(HaveOccurred())
+					state, err := performgen.RunDialect(string(input), dialect)
+					if expected, readErr := ioutil.ReadFile(base + ".err"); readErr == nil {
+						Expect(err).To(MatchError(strings.TrimSpace(string(expected))))
+						return
+					} else if !os.IsNotExist(readErr) {
+						Fail(readErr.Error())
+					}
+					Expect(err).ToNot(HaveOccurred())
+					expected, err := ioutil.ReadFile(base + ".txt")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(textnote.Export(state.Sequence, 0)).To(Equal(strings.TrimSpace(string(expected))))
+
+					By("printing MML that generates the same segments")
+					printed := printMML(string(input), dialect)
+					Expect(printMML(printed, dialect)).To(Equal(printed))
+					printedState, err := performgen.RunDialect(printed, dialect)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(printedState.Sequence.Segments()).To(Equal(state.Sequence.Segments()))
+				})
+			}
+		})
+	}
 })
+
+func printMML(input string, dialect *mml.Dialect) string {
+	ast, err := mml.NewDialectParser(strings.NewReader(input), dialect).Parse()
+	Expect(err).ToNot(HaveOccurred())
+	buf := new(bytes.Buffer)
+	Expect(mml.Print(buf, ast, mml.PrintOptions{Dialect: dialect, BarNumbers: true})).To(Succeed())
+	return buf.String()
+}
@@ -0,0 +1,106 @@
+// Package preview renders a sequence of perform notes as audio so that a
+// track can be listened to without the game. The notes are played with a
+// simple plucked tone that fades out, similar to the in-game harp.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+)
+
+// SampleRate is the number of samples per second of the rendered audio
+const SampleRate = 22050
+
+// Decay is how long it takes for a note to fade to silence
+const Decay = 1500 * time.Millisecond
+
+// amplitude is the peak amplitude of a single note, leaving room for several
+// notes to be mixed together before clipping
+const amplitude = 0.3
+
+// Render returns the samples of a sequence, including time at the end for
+// the last notes to fade out. It returns the error of the context if the
+// context is done before every note has been rendered.
+func Render(ctx context.Context, seq encoding.Sequence) ([]int16, error) {
+	total := durationSamples(seq.Length() + Decay)
+	mix := make([]float64, total)
+	// Each note is rendered once and then mixed in wherever it is played
+	tones := make(map[encoding.Note][]float64)
+	for _, n := range seq.Timeline() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		t, ok := tones[n.Note]
+		if !ok {
+			t = tone(Frequency(n.Note))
+			tones[n.Note] = t
+		}
+		start := durationSamples(n.At)
+		for i := 0; i < len(t) && start+i < total; i++ {
+			mix[start+i] += t[i]
+		}
+	}
+	samples := make([]int16, total)
+	for i, v := range mix {
+		samples[i] = int16(math.Max(-1, math.Min(1, v)) * math.MaxInt16)
+	}
+	return samples, nil
+}
+
+// tone returns the samples of a single note at the frequency, from when it
+// is played until it has faded out
+func tone(freq float64) []float64 {
+	samples := make([]float64, durationSamples(Decay))
+	for i := range samples {
+		t := float64(i) / SampleRate
+		envelope := math.Exp(-5 * float64(i) / float64(len(samples)))
+		if i < 64 {
+			// Short attack to avoid clicks
+			envelope *= float64(i) / 64
+		}
+		wave := math.Sin(2*math.Pi*freq*t) + 0.3*math.Sin(4*math.Pi*freq*t)
+		samples[i] = amplitude * envelope * wave
+	}
+	return samples
+}
+
+// Frequency returns the frequency in Hz of a perform note, where note 22
+// (`A (+0)`) is 440 Hz
+func Frequency(n encoding.Note) float64 {
+	return 440 * math.Pow(2, float64(int(n)-22)/12)
+}
+
+// WriteWAV writes the sequence as a 16-bit mono WAV file
+func WriteWAV(ctx context.Context, w io.Writer, seq encoding.Sequence) error {
+	samples, err := Render(ctx, seq)
+	if err != nil {
+		return err
+	}
+	dataSize := uint32(len(samples) * 2)
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, 36+dataSize)
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{16})
+	// PCM format, 1 channel
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{1, 1})
+	// Sample rate and byte rate
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{SampleRate, SampleRate * 2})
+	// Block align and bits per sample
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{2, 16})
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, dataSize)
+	_ = binary.Write(buf, binary.LittleEndian, samples)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func durationSamples(d time.Duration) int {
+	return int(d * SampleRate / time.Second)
+}
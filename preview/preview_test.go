@@ -0,0 +1,79 @@
+package preview_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/preview"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Preview", func() {
+	Describe("Frequency", func() {
+		It("tunes A (+0) to 440 Hz", func() {
+			Expect(preview.Frequency(22)).To(BeNumerically("~", 440, 0.001))
+			Expect(preview.Frequency(10)).To(BeNumerically("~", 220, 0.001))
+			Expect(preview.Frequency(13)).To(BeNumerically("~", 261.626, 0.001))
+		})
+	})
+	Describe("Render", func() {
+		It("renders the length of the sequence along with the decay of the last note", func() {
+			seq := encoding.Sequence{encoding.Delay(100), encoding.Note(22), encoding.Delay(250), encoding.Delay(150)}
+			samples, err := preview.Render(context.Background(), seq)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(samples).To(HaveLen(preview.SampleRate * 2))
+		})
+		It("is silent before the first note and after it has faded", func() {
+			seq := encoding.Sequence{encoding.Delay(100), encoding.Note(22)}
+			samples, err := preview.Render(context.Background(), seq)
+			Expect(err).ToNot(HaveOccurred())
+			for _, s := range samples[:preview.SampleRate/10] {
+				Expect(s).To(BeZero())
+			}
+			var peak int16
+			for _, s := range samples[preview.SampleRate/10:] {
+				if s > peak {
+					peak = s
+				}
+			}
+			Expect(peak).To(BeNumerically(">", 5000))
+			Expect(samples[len(samples)-1]).To(BeNumerically("~", 0, 100))
+		})
+		It("mixes notes that are played at the same time", func() {
+			one, err := preview.Render(context.Background(), encoding.Sequence{encoding.Note(13)})
+			Expect(err).ToNot(HaveOccurred())
+			two, err := preview.Render(context.Background(), encoding.Sequence{encoding.Note(13), encoding.Note(13)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(two[1000]).To(BeNumerically("~", 2*int(one[1000]), 1))
+		})
+		It("stops when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := preview.Render(ctx, encoding.Sequence{encoding.Note(22)})
+			Expect(err).To(MatchError(context.Canceled))
+		})
+	})
+	Describe("WriteWAV", func() {
+		It("writes a 16-bit mono WAV file", func() {
+			buf := new(bytes.Buffer)
+			Expect(preview.WriteWAV(context.Background(), buf, encoding.Sequence{encoding.Note(1)})).To(Succeed())
+			data := buf.Bytes()
+			samples := preview.SampleRate * 3 / 2
+
+			Expect(string(data[0:4])).To(Equal("RIFF"))
+			Expect(binary.LittleEndian.Uint32(data[4:8])).To(Equal(uint32(36 + samples*2)))
+			Expect(string(data[8:16])).To(Equal("WAVEfmt "))
+			Expect(binary.LittleEndian.Uint16(data[20:22])).To(Equal(uint16(1)))
+			Expect(binary.LittleEndian.Uint16(data[22:24])).To(Equal(uint16(1)))
+			Expect(binary.LittleEndian.Uint32(data[24:28])).To(Equal(uint32(preview.SampleRate)))
+			Expect(binary.LittleEndian.Uint16(data[34:36])).To(Equal(uint16(16)))
+			Expect(string(data[36:40])).To(Equal("data"))
+			Expect(binary.LittleEndian.Uint32(data[40:44])).To(Equal(uint32(samples * 2)))
+			Expect(data).To(HaveLen(44 + samples*2))
+		})
+	})
+})
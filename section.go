@@ -0,0 +1,176 @@
+package performgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// Point is a point in a track where a section starts or ends. Only one of its
+// fields should be set, except that a Marker can have a Time to use if the
+// track has no marker with that name, for names like 90s that are also times.
+// A zero Point is the start of the track when a section starts at it, and the
+// end of the track when a section ends at it.
+type Point struct {
+	// Time is the time since the start of the track
+	Time time.Duration
+	// Measure is the number of a measure, starting at 1. The point is at the
+	// start of the measure.
+	Measure int
+	// Position is a position in the MML. The point is at the start of the
+	// first command that ends after the position.
+	Position mml.Position
+	// Marker is the name of a rehearsal marker in the MML, like chorus for
+	// `*chorus`
+	Marker string
+}
+
+// Section is the part of a track between two points
+type Section struct {
+	From Point
+	To   Point
+	// BeatsPerMeasure is the number of quarter note beats in a measure, for
+	// points given as a measure. If it is zero, there are 4 beats in a measure.
+	BeatsPerMeasure int
+}
+
+// GenerateSection is like Generate, but only returns the segments of the
+// section of the track
+func GenerateSection(input string, section Section) ([]encoding.PerformSegment, error) {
+	state := new(mml.State)
+	if err := RunSection(input, state, section); err != nil {
+		return nil, err
+	}
+	return state.Sequence.Segments(), nil
+}
+
+// RunSection is like RunState, but only leaves the part of the sequence and
+// the tempo changes in the section on the state. The whole track is still
+// executed, so the section is played with the tempo, octave, default length,
+// and instrument that were set before it.
+func RunSection(input string, state *mml.State, section Section) error {
+	d := state.Dialect
+	if d == nil {
+		d = mml.Performgen
+	}
+	ast, err := mml.NewDialectParser(bytes.NewReader([]byte(input)), d).Parse()
+	if err != nil {
+		return err
+	}
+	starts := make([]commandStart, len(ast.Sequence))
+	for i, cmd := range ast.Sequence {
+		starts[i] = commandStart{at: state.Elapsed, beats: state.Beats, span: ast.Spans[i]}
+		if err := cmd.Execute(state); err != nil {
+			return &mml.ExecError{Position: ast.Positions[i], Span: ast.Spans[i], Command: cmd, Err: err}
+		}
+	}
+	end := commandStart{at: state.Elapsed, beats: state.Beats}
+
+	beats := section.BeatsPerMeasure
+	if beats == 0 {
+		beats = 4
+	}
+	from, err := section.From.resolve(state, starts, end, beats, 0)
+	if err != nil {
+		return err
+	}
+	to, err := section.To.resolve(state, starts, end, beats, end.at)
+	if err != nil {
+		return err
+	}
+	if to < from {
+		return errors.New("the section ends before it starts")
+	}
+	state.Sequence = state.Sequence.Between(from, to)
+	state.TempoChanges = tempoChangesBetween(state.TempoChanges, from, to)
+	state.Markers = markersBetween(state.Markers, from, to)
+	return nil
+}
+
+// commandStart is when a command of the MML starts playing
+type commandStart struct {
+	at    time.Duration
+	beats float64
+	span  mml.Span
+}
+
+// resolve returns the time of the point, given the state after executing the
+// track, when each command starts, and when the track ends. A zero point is at
+// the time def.
+func (p Point) resolve(state *mml.State, starts []commandStart, end commandStart, beatsPerMeasure int, def time.Duration) (time.Duration, error) {
+	switch {
+	case p.Marker != "":
+		for _, m := range state.Markers {
+			if m.Name == p.Marker {
+				return m.At, nil
+			}
+		}
+		if p.Time == 0 {
+			return 0, fmt.Errorf("unknown marker: %s", p.Marker)
+		}
+		return Point{Time: p.Time}.resolve(state, starts, end, beatsPerMeasure, def)
+	case p.Measure != 0:
+		if p.Measure < 1 {
+			return 0, fmt.Errorf("invalid measure: %d", p.Measure)
+		}
+		beat := float64((p.Measure - 1) * beatsPerMeasure)
+		for _, s := range append(starts, end) {
+			// Beats can be a little off from summing fractions of a beat
+			if s.beats >= beat-1e-9 {
+				return s.at, nil
+			}
+		}
+		return 0, fmt.Errorf("measure %d is after the end of the track", p.Measure)
+	case p.Position.Line != 0:
+		for _, s := range starts {
+			if positionBefore(p.Position, s.span.End) {
+				return s.at, nil
+			}
+		}
+		return end.at, nil
+	case p.Time != 0:
+		if p.Time < 0 || p.Time > end.at {
+			return 0, fmt.Errorf("%s is outside of the track, which is %s long", p.Time, end.at)
+		}
+		return p.Time, nil
+	}
+	return def, nil
+}
+
+func positionBefore(a, b mml.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// markersBetween returns the markers from the time from up to the time to,
+// relative to from. Their beats still count from the start of the track, so
+// that they are in the same measures as in the whole track.
+func markersBetween(markers []mml.Marker, from, to time.Duration) []mml.Marker {
+	var part []mml.Marker
+	for _, m := range markers {
+		if m.At >= from && m.At < to {
+			m.At -= from
+			part = append(part, m)
+		}
+	}
+	return part
+}
+
+// tempoChangesBetween returns the tempo changes from the time from up to the
+// time to, relative to from. The tempo that was set at from is kept at the
+// start.
+func tempoChangesBetween(changes []mml.TempoChange, from, to time.Duration) []mml.TempoChange {
+	var part []mml.TempoChange
+	for _, c := range changes {
+		switch {
+		case c.At <= from:
+			part = []mml.TempoChange{{At: 0, Tempo: c.Tempo}}
+		case c.At < to:
+			part = append(part, mml.TempoChange{At: c.At - from, Tempo: c.Tempo})
+		}
+	}
+	return part
+}
@@ -0,0 +1,115 @@
+package performgen_test
+
+import (
+	"time"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sections", func() {
+	const song = "t120 l4 o4 cdef gab>c t60 l8 dc"
+
+	run := func(section performgen.Section) (*mml.State, error) {
+		state := new(mml.State)
+		return state, performgen.RunSection(song, state, section)
+	}
+
+	It("generates the measures of the section with the state set before them", func() {
+		segments, err := performgen.GenerateSection(song, performgen.Section{
+			From: performgen.Point{Measure: 2},
+			To:   performgen.Point{Measure: 3},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := performgen.Generate("t120 l4 o4 gab>c")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(segments).To(Equal(expected))
+	})
+	It("starts and ends at times", func() {
+		state, err := run(performgen.Section{
+			From: performgen.Point{Time: 1750 * time.Millisecond},
+			To:   performgen.Point{Time: 2500 * time.Millisecond},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence).To(Equal(encoding.Sequence{
+			encoding.Delay(250), encoding.Note(20), encoding.Delay(250), encoding.Delay(250),
+		}))
+	})
+	It("starts at a position in the MML and keeps the tempo changes in the section", func() {
+		state, err := run(performgen.Section{
+			From: performgen.Point{Position: mml.Position{Line: 1, Column: 20}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+			{Note: 25, At: 0},
+			{Note: 27, At: 500 * time.Millisecond},
+			{Note: 25, At: 1000 * time.Millisecond},
+		}))
+		Expect(state.TempoChanges).To(Equal([]mml.TempoChange{
+			{At: 0, Tempo: 120},
+			{At: 500 * time.Millisecond, Tempo: 60},
+		}))
+	})
+	It("counts measures with the given number of beats", func() {
+		state, err := run(performgen.Section{
+			From:            performgen.Point{Measure: 3},
+			To:              performgen.Point{Measure: 4},
+			BeatsPerMeasure: 3,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+			{Note: 24, At: 0},
+			{Note: 25, At: 500 * time.Millisecond},
+			{Note: 27, At: 1000 * time.Millisecond},
+			{Note: 25, At: 1500 * time.Millisecond},
+		}))
+	})
+	It("starts and ends at markers and keeps the markers in the section", func() {
+		state := new(mml.State)
+		err := performgen.RunSection("t120 l4 o4 *intro cd *verse ef *chorus g", state, performgen.Section{
+			From: performgen.Point{Marker: "verse"},
+			To:   performgen.Point{Marker: "chorus"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence.Timeline()).To(Equal([]encoding.TimedNote{
+			{Note: 17, At: 0},
+			{Note: 18, At: 500 * time.Millisecond},
+		}))
+		Expect(state.Markers).To(Equal([]mml.Marker{{Name: "verse", At: 0, Beats: 2}}))
+
+		_, err = run(performgen.Section{From: performgen.Point{Marker: "bridge"}})
+		Expect(err).To(MatchError("unknown marker: bridge"))
+	})
+	It("uses the time of a marker point only if there is no marker with that name", func() {
+		state := new(mml.State)
+		err := performgen.RunSection("t120 l4 o4 c *1s def", state, performgen.Section{
+			From: performgen.Point{Marker: "1s", Time: time.Second},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence.Timeline()[0]).To(Equal(encoding.TimedNote{Note: 15, At: 0}))
+
+		state = new(mml.State)
+		err = performgen.RunSection("t120 l4 o4 cd *1s ef", state, performgen.Section{
+			From: performgen.Point{Marker: "500ms", Time: 500 * time.Millisecond},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Sequence.Timeline()[0]).To(Equal(encoding.TimedNote{Note: 15, At: 0}))
+	})
+	It("errors if a point is outside of the track", func() {
+		_, err := run(performgen.Section{From: performgen.Point{Measure: 5}})
+		Expect(err).To(MatchError("measure 5 is after the end of the track"))
+		_, err = run(performgen.Section{To: performgen.Point{Time: time.Minute}})
+		Expect(err).To(MatchError("1m0s is outside of the track, which is 5s long"))
+	})
+	It("errors if the section ends before it starts", func() {
+		_, err := run(performgen.Section{
+			From: performgen.Point{Measure: 2},
+			To:   performgen.Point{Time: time.Second},
+		})
+		Expect(err).To(MatchError("the section ends before it starts"))
+	})
+})
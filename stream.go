@@ -0,0 +1,69 @@
+package performgen
+
+import (
+	"io"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+)
+
+// Stream generates perform segments from MML while it's being read, so that a
+// long track can start playing before all of it has been parsed. Only the
+// notes that haven't been packed into a segment yet are kept in memory.
+type Stream struct {
+	parser    *mml.Parser
+	state     *mml.State
+	segmenter encoding.Segmenter
+	pending   []encoding.PerformSegment
+	done      bool
+}
+
+// NewStream returns a stream that reads MML in the given dialect from r
+func NewStream(r io.Reader, d *mml.Dialect) *Stream {
+	return &Stream{
+		parser: mml.NewDialectParser(r, d),
+		state:  &mml.State{Dialect: d},
+	}
+}
+
+// Next returns the next segment as soon as its block is full. It returns
+// io.EOF after the last segment has been returned. Errors are returned in
+// the same format as Generate.
+func (s *Stream) Next() (encoding.PerformSegment, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return encoding.PerformSegment{}, io.EOF
+		}
+		if err := s.step(); err != nil {
+			s.done = true
+			return encoding.PerformSegment{}, err
+		}
+	}
+	segment := s.pending[0]
+	s.pending = s.pending[1:]
+	return segment, nil
+}
+
+// step parses and executes a single command, packing the steps it emits into
+// segments
+func (s *Stream) step() error {
+	cmd, span, err := s.parser.Next()
+	if err != nil {
+		return err
+	}
+	if cmd == nil {
+		s.done = true
+		if segment, ok := s.segmenter.Flush(); ok {
+			s.pending = append(s.pending, segment)
+		}
+		return nil
+	}
+	if err := cmd.Execute(s.state); err != nil {
+		return &mml.ExecError{Position: span.Start, Span: span, Command: cmd, Err: err}
+	}
+	for _, step := range s.state.Sequence {
+		s.pending = append(s.pending, s.segmenter.Add(step)...)
+	}
+	s.state.Sequence = s.state.Sequence[:0]
+	return nil
+}
@@ -0,0 +1,75 @@
+package performgen_test
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ff14wed/performgen"
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/mml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func readAll(s *performgen.Stream) ([]encoding.PerformSegment, error) {
+	segments := []encoding.PerformSegment{}
+	for {
+		segment, err := s.Next()
+		if err == io.EOF {
+			return segments, nil
+		}
+		if err != nil {
+			return segments, err
+		}
+		segments = append(segments, segment)
+	}
+}
+
+var _ = Describe("Stream", func() {
+	It("generates the same segments as Generate", func() {
+		input := "t88 b2al2b+. o5 c8d8e8f8g8a8b8>c8 <<c1 l16 cdefgab>c<bagfedc"
+		expected, err := performgen.Generate(input)
+		Expect(err).ToNot(HaveOccurred())
+
+		segments, err := readAll(performgen.NewStream(strings.NewReader(input), mml.Performgen))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(segments).To(Equal(expected))
+	})
+	It("returns segments before the rest of the input has been written", func(done Done) {
+		r, w := io.Pipe()
+		defer w.Close()
+		go func() {
+			defer GinkgoRecover()
+			_, err := w.Write([]byte(strings.Repeat("c8 ", 12)))
+			Expect(err).ToNot(HaveOccurred())
+		}()
+
+		s := performgen.NewStream(r, mml.Performgen)
+		segment, err := s.Next()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(segment.Block.Length).To(Equal(byte(30)))
+		close(done)
+	}, 1)
+	It("returns io.EOF after the last segment", func() {
+		s := performgen.NewStream(strings.NewReader("c"), mml.Performgen)
+		_, err := s.Next()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = s.Next()
+		Expect(err).To(Equal(io.EOF))
+		_, err = s.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+	It("returns the segments generated before an error", func() {
+		s := performgen.NewStream(strings.NewReader(strings.Repeat("c8 ", 12)+"o7"), mml.Performgen)
+		segments, err := readAll(s)
+		Expect(err).To(MatchError("execution error at line 1, column 37: cannot set octave to anything other than 3, 4, 5, or 6"))
+		Expect(segments).To(HaveLen(1))
+		_, err = s.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+	It("uses the dialect", func() {
+		_, err := readAll(performgen.NewStream(strings.NewReader("kc"), mml.Mabinogi))
+		Expect(err).To(MatchError("invalid token 'k' at line 1, column 1"))
+	})
+})
@@ -0,0 +1,121 @@
+// Package textnote reads and writes the text format that players share
+// sheets in. Notes are written the same way as their labels in game, like
+// `A (+0), Bb (-1), C# (+1)`, and are separated by commas or new lines. Each
+// note can be followed by the time to wait before the next note, like
+// `A (+0) 250ms`. A rest is written as `R` followed by its length, like
+// `R 1s`.
+package textnote
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+)
+
+var entryPattern = regexp.MustCompile(`^(?:([A-Ga-g])\s*([#b]?)\s*\(\s*([+-]?\d)\s*\)|([Rr]))(?:\s+(\S+))?$`)
+
+var noteOffsets = map[string]int{
+	"C": 0,
+	"D": 2,
+	"E": 4,
+	"F": 5,
+	"G": 7,
+	"A": 9,
+	"B": 11,
+}
+
+// Parse reads notes in the text format. Notes without a length are followed
+// by the given interval. If the interval is 0, every note must have a length.
+func Parse(r io.Reader, interval time.Duration) (encoding.Sequence, error) {
+	seq := encoding.Sequence{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		for _, entry := range strings.Split(scanner.Text(), ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			steps, err := parseEntry(entry, interval)
+			if err != nil {
+				return nil, fmt.Errorf("%s at line %d", err, lineNum)
+			}
+			seq = append(seq, steps...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return seq, nil
+}
+
+func parseEntry(entry string, interval time.Duration) (encoding.Sequence, error) {
+	m := entryPattern.FindStringSubmatch(entry)
+	if m == nil {
+		return nil, fmt.Errorf("invalid note '%s'", entry)
+	}
+	length := interval
+	if m[5] != "" {
+		var err error
+		length, err = time.ParseDuration(m[5])
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid length '%s'", m[5])
+		}
+	} else if interval == 0 {
+		return nil, fmt.Errorf("missing length for '%s'", entry)
+	}
+	seq := encoding.Sequence{}
+	if m[4] == "" {
+		id := noteOffsets[strings.ToUpper(m[1])]
+		switch m[2] {
+		case "#":
+			id++
+		case "b":
+			id--
+		}
+		octave, _ := strconv.Atoi(m[3])
+		id += (octave+1)*12 + 1
+		if id < 1 || id > 37 {
+			return nil, fmt.Errorf("note out of range '%s'", entry)
+		}
+		seq = append(seq, encoding.Note(id))
+	}
+	return append(seq, encoding.Delays(int(length/time.Millisecond))...), nil
+}
+
+// Export writes the notes of a sequence in the text format. Notes followed by
+// exactly the given interval are written without a length. If the interval is
+// 0, every length is written.
+func Export(seq encoding.Sequence, interval time.Duration) string {
+	var entries []string
+	notes := seq.Timeline()
+	end := seq.Length()
+	if len(notes) > 0 && notes[0].At > 0 {
+		entries = append(entries, "R "+formatLength(notes[0].At))
+	} else if len(notes) == 0 && end > 0 {
+		entries = append(entries, "R "+formatLength(end))
+	}
+	for i, n := range notes {
+		next := end
+		if i+1 < len(notes) {
+			next = notes[i+1].At
+		}
+		entry := n.Note.Label()
+		if length := next - n.At; length != interval || interval == 0 {
+			entry += " " + formatLength(length)
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ", ")
+}
+
+func formatLength(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+}
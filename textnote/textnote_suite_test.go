@@ -0,0 +1,13 @@
+package textnote_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTextnote(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Textnote Suite")
+}
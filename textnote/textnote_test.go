@@ -0,0 +1,73 @@
+package textnote_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ff14wed/performgen/encoding"
+	"github.com/ff14wed/performgen/textnote"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Textnote", func() {
+	Describe("Parse", func() {
+		It("plays each note for the fixed interval", func() {
+			seq, err := textnote.Parse(strings.NewReader("A (+0), Bb (-1), C# (+1)\nC(+2)"), 300*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seq).To(Equal(encoding.Sequence{
+				encoding.Note(22), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(11), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(26), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(37), encoding.Delay(250), encoding.Delay(50),
+			}))
+		})
+		It("uses the length of each note and rest if specified", func() {
+			seq, err := textnote.Parse(strings.NewReader("g (0) 100ms, R 1s, e (-1) 20ms, F# (+1)"), 50*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seq).To(Equal(encoding.Sequence{
+				encoding.Note(20), encoding.Delay(100),
+				encoding.Delay(250), encoding.Delay(250), encoding.Delay(250), encoding.Delay(250),
+				encoding.Note(5), encoding.Delay(20),
+				encoding.Note(31), encoding.Delay(50),
+			}))
+		})
+		It("errors if there is no interval and a note has no length", func() {
+			_, err := textnote.Parse(strings.NewReader("A (+0) 1s,\nB (+0)"), 0)
+			Expect(err).To(MatchError("missing length for 'B (+0)' at line 2"))
+		})
+		It("errors on invalid notes", func() {
+			_, err := textnote.Parse(strings.NewReader("A (+0), H (+0)"), time.Second)
+			Expect(err).To(MatchError("invalid note 'H (+0)' at line 1"))
+			_, err = textnote.Parse(strings.NewReader("C# (+2)"), time.Second)
+			Expect(err).To(MatchError("note out of range 'C# (+2)' at line 1"))
+			_, err = textnote.Parse(strings.NewReader("C (+1) 5 beats"), time.Second)
+			Expect(err).To(MatchError("invalid note 'C (+1) 5 beats' at line 1"))
+			_, err = textnote.Parse(strings.NewReader("C (+1) 5"), time.Second)
+			Expect(err).To(MatchError("invalid length '5' at line 1"))
+		})
+	})
+	Describe("Export", func() {
+		var seq encoding.Sequence
+		BeforeEach(func() {
+			seq = encoding.Sequence{
+				encoding.Delay(100),
+				encoding.Note(22), encoding.Delay(250), encoding.Delay(50),
+				encoding.Note(11), encoding.Delay(250),
+				encoding.Note(26), encoding.Delay(250), encoding.Delay(50),
+			}
+		})
+		It("writes the length of every note", func() {
+			Expect(textnote.Export(seq, 0)).To(Equal("R 100ms, A (+0) 300ms, Bb (-1) 250ms, C# (+1) 300ms"))
+		})
+		It("omits lengths equal to the interval", func() {
+			Expect(textnote.Export(seq, 300*time.Millisecond)).To(Equal("R 100ms, A (+0), Bb (-1) 250ms, C# (+1)"))
+		})
+		It("round trips through Parse", func() {
+			parsed, err := textnote.Parse(strings.NewReader(textnote.Export(seq, 300*time.Millisecond)), 300*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(seq))
+		})
+	})
+})